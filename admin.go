@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"golang.org/x/term"
+	"gorm.io/gorm"
+)
+
+func runCreateAdmin(args []string) {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := flags.String("username", "", "username of the admin (required)")
+	email := flags.String("email", "", "email of a new admin")
+	fullName := flags.String("full-name", "", "full name of a new admin")
+	flags.Parse(args)
+
+	if *username == "" {
+		log.Fatal("create-admin: --username is required")
+	}
+
+	a, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	// note : an existing account is promoted and keeps its password
+	user, err := a.UserRepo.FindByUsername(ctx, *username)
+	if err == nil {
+		if user.Role == models.RoleAdmin {
+			fmt.Printf("User %s is already an admin\n", user.Username)
+			return
+		}
+		user.Role = models.RoleAdmin
+		if err := a.UserRepo.Update(ctx, user); err != nil {
+			log.Fatalf("could not promote user: %v", err)
+		}
+		fmt.Printf("Promoted user %s to admin\n", user.Username)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Fatalf("could not look up user: %v", err)
+	}
+
+	if *email == "" || *fullName == "" {
+		log.Fatal("create-admin: --email and --full-name are required for a new user")
+	}
+	password, err := readAdminPassword()
+	if err != nil {
+		log.Fatalf("could not read password: %v", err)
+	}
+	if len(password) < 6 {
+		log.Fatal("create-admin: the password must be at least 6 characters")
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Fatalf("could not hash password: %v", err)
+	}
+
+	now := time.Now()
+	admin := &models.User{
+		FullName:        *fullName,
+		Username:        *username,
+		Email:           *email,
+		Password:        hashedPassword,
+		Role:            models.RoleAdmin,
+		EmailVerifiedAt: &now,
+	}
+	if err := a.UserRepo.Create(ctx, admin); err != nil {
+		log.Fatalf("could not create admin: %v", err)
+	}
+	fmt.Printf("Created admin %s (id %d)\n", admin.Username, admin.ID)
+}
+
+// readAdminPassword takes the password from ADMIN_PASSWORD, or asks for it
+// without echo. It is never a flag, which would leave it in the shell history
+// and the process list.
+func readAdminPassword() (string, error) {
+	if password := os.Getenv("ADMIN_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// note : allows piping the password in from a secret store
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(password), err
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidUserID = apperror.Validation("INVALID_USER_ID", "invalid user ID")
+
+type AdminHandler struct {
+	authService services.AuthService
+}
+
+func NewAdminHandler(authService services.AuthService) *AdminHandler {
+	return &AdminHandler{authService}
+}
+
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if err := h.authService.UnlockUser(c.Request.Context(), uint(userID)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAdminHandler_UnlockUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		userID         string
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:   "Positive: User unlocked",
+			userID: "1",
+			mockSetup: func(m *MockAuthService) {
+				m.On("UnlockUser", mock.Anything, uint(1)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Invalid user ID",
+			userID:         "abc",
+			mockSetup:      func(m *MockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Negative: User not found",
+			userID: "999",
+			mockSetup: func(m *MockAuthService) {
+				m.On("UnlockUser", mock.Anything, uint(999)).Return(services.ErrUserNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Negative: Service error",
+			userID: "1",
+			mockSetup: func(m *MockAuthService) {
+				m.On("UnlockUser", mock.Anything, uint(1)).Return(errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAdminHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/admin/users/:id/unlock", handler.UnlockUser)
+
+			req := httptest.NewRequest("POST", "/admin/users/"+tt.userID+"/unlock", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewAdminHandler(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAdminHandler(mockService)
+
+	assert.NotNil(t, handler)
+	assert.Equal(t, mockService, handler.authService)
+}
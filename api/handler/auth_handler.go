@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
+var errTwoFactorLoginFailed = apperror.Unauthorized("INVALID_TWO_FACTOR_CODE", "invalid two-factor code")
+
 type AuthHandler struct {
 	authService services.AuthService
 }
@@ -19,13 +24,13 @@ func NewAuthHandler(authService services.AuthService) *AuthHandler {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
-	_, err := h.authService.Register(&req)
+	_, err := h.authService.Register(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user", "details": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -35,13 +40,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
-	loginResponse, err := h.authService.Login(&req)
+	loginResponse, err := h.authService.Login(c.Request.Context(), &req, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, loginResponse)
@@ -50,3 +55,139 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
 }
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully"})
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), userID.(uint)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+func (h *AuthHandler) VerifyTwoFactorLogin(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	loginResponse, err := h.authService.VerifyTwoFactorLogin(c.Request.Context(), &req, c.ClientIP())
+	if err != nil {
+		// note : a wrong code fails the login, unlike a wrong code while
+		// managing two-factor settings
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			err = errTwoFactorLoginFailed.Wrap(err)
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse)
+}
+
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	resp, err := h.authService.EnrollTwoFactor(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	resp, err := h.authService.ConfirmTwoFactor(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(c.Request.Context(), userID.(uint), &req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
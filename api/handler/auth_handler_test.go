@@ -2,13 +2,17 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -18,27 +22,81 @@ type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Register(req *models.RegisterRequest) (*models.User, error) {
-	args := m.Called(req)
+func (m *MockAuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error) {
+	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockAuthService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
-	args := m.Called(req)
+func (m *MockAuthService) Login(ctx context.Context, req *models.LoginRequest, clientIP string) (*models.LoginResponse, error) {
+	args := m.Called(ctx, req, clientIP)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.LoginResponse), args.Error(1)
 }
 
-func (m *MockAuthService) ValidateToken(token string) (uint, error) {
-	args := m.Called(token)
+func (m *MockAuthService) ValidateToken(ctx context.Context, token string) (uint, error) {
+	args := m.Called(ctx, token)
 	return args.Get(0).(uint), args.Error(1)
 }
 
+func (m *MockAuthService) ForgotPassword(ctx context.Context, req *models.ForgotPasswordRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResendVerification(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) VerifyTwoFactorLogin(ctx context.Context, req *models.TwoFactorLoginRequest, clientIP string) (*models.LoginResponse, error) {
+	args := m.Called(ctx, req, clientIP)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginResponse), args.Error(1)
+}
+
+func (m *MockAuthService) EnrollTwoFactor(ctx context.Context, userID uint) (*models.TwoFactorEnrollResponse, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TwoFactorEnrollResponse), args.Error(1)
+}
+
+func (m *MockAuthService) ConfirmTwoFactor(ctx context.Context, userID uint, req *models.TwoFactorCodeRequest) (*models.TwoFactorConfirmResponse, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TwoFactorConfirmResponse), args.Error(1)
+}
+
+func (m *MockAuthService) DisableTwoFactor(ctx context.Context, userID uint, req *models.TwoFactorCodeRequest) error {
+	args := m.Called(ctx, userID, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) UnlockUser(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
 func TestAuthHandler_Register(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -62,7 +120,7 @@ func TestAuthHandler_Register(t *testing.T) {
 				PostalCode:  "12345",
 			},
 			mockSetup: func(m *MockAuthService) {
-				m.On("Register", mock.AnythingOfType("*models.RegisterRequest")).Return(&models.User{ID: 1}, nil)
+				m.On("Register", mock.Anything, mock.AnythingOfType("*models.RegisterRequest")).Return(&models.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusCreated,
 			expectedError:  false,
@@ -84,6 +142,7 @@ func TestAuthHandler_Register(t *testing.T) {
 
 			handler := NewAuthHandler(mockService)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.POST("/register", handler.Register)
 
 			var body []byte
@@ -121,7 +180,7 @@ func TestAuthHandler_Login(t *testing.T) {
 				Password: "password123",
 			},
 			mockSetup: func(m *MockAuthService) {
-				m.On("Login", mock.AnythingOfType("*models.LoginRequest")).Return(&models.LoginResponse{Token: "token123"}, nil)
+				m.On("Login", mock.Anything, mock.AnythingOfType("*models.LoginRequest"), mock.AnythingOfType("string")).Return(&models.LoginResponse{Token: "token123"}, nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -131,6 +190,17 @@ func TestAuthHandler_Login(t *testing.T) {
 			mockSetup:      func(m *MockAuthService) {},
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name: "Positive: Two-factor challenge",
+			requestBody: models.LoginRequest{
+				Username: "testuser",
+				Password: "password123",
+			},
+			mockSetup: func(m *MockAuthService) {
+				m.On("Login", mock.Anything, mock.AnythingOfType("*models.LoginRequest"), mock.AnythingOfType("string")).Return(&models.LoginResponse{TwoFactorRequired: true, ChallengeToken: "challenge"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name: "Negative: Invalid credentials",
 			requestBody: models.LoginRequest{
@@ -138,10 +208,21 @@ func TestAuthHandler_Login(t *testing.T) {
 				Password: "wrong",
 			},
 			mockSetup: func(m *MockAuthService) {
-				m.On("Login", mock.AnythingOfType("*models.LoginRequest")).Return(nil, errors.New("invalid credentials"))
+				m.On("Login", mock.Anything, mock.AnythingOfType("*models.LoginRequest"), mock.AnythingOfType("string")).Return(nil, services.ErrInvalidCredentials)
 			},
 			expectedStatus: http.StatusUnauthorized,
 		},
+		{
+			name: "Negative: Account locked",
+			requestBody: models.LoginRequest{
+				Username: "testuser",
+				Password: "password123",
+			},
+			mockSetup: func(m *MockAuthService) {
+				m.On("Login", mock.Anything, mock.AnythingOfType("*models.LoginRequest"), mock.AnythingOfType("string")).Return(nil, services.ErrLoginThrottled.WithRetryAfter(15*time.Minute))
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
 	}
 
 	for _, tt := range tests {
@@ -151,6 +232,7 @@ func TestAuthHandler_Login(t *testing.T) {
 
 			handler := NewAuthHandler(mockService)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.POST("/login", handler.Login)
 
 			var body []byte
@@ -191,6 +273,414 @@ func TestAuthHandler_Logout(t *testing.T) {
 	assert.Equal(t, "Successfully logged out", response["message"])
 }
 
+func TestAuthHandler_ForgotPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Valid email",
+			requestBody: models.ForgotPasswordRequest{Email: "test@example.com"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("ForgotPassword", mock.Anything, mock.AnythingOfType("*models.ForgotPasswordRequest")).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Invalid email",
+			requestBody:    map[string]string{"email": "not-an-email"},
+			mockSetup:      func(m *MockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Mail sender error",
+			requestBody: models.ForgotPasswordRequest{Email: "test@example.com"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("ForgotPassword", mock.Anything, mock.AnythingOfType("*models.ForgotPasswordRequest")).Return(errors.New("smtp down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAuthHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/forgot-password", handler.ForgotPassword)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/forgot-password", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_ResetPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Valid token",
+			requestBody: models.ResetPasswordRequest{Token: "abc", NewPassword: "newpassword"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("ResetPassword", mock.Anything, mock.AnythingOfType("*models.ResetPasswordRequest")).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Password too short",
+			requestBody:    models.ResetPasswordRequest{Token: "abc", NewPassword: "123"},
+			mockSetup:      func(m *MockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Expired token",
+			requestBody: models.ResetPasswordRequest{Token: "abc", NewPassword: "newpassword"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("ResetPassword", mock.Anything, mock.AnythingOfType("*models.ResetPasswordRequest")).Return(services.ErrInvalidResetToken)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAuthHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/reset-password", handler.ResetPassword)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/reset-password", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_VerifyEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Valid token",
+			requestBody: models.VerifyEmailRequest{Token: "abc"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("VerifyEmail", mock.Anything, mock.AnythingOfType("*models.VerifyEmailRequest")).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Missing token",
+			requestBody:    map[string]string{},
+			mockSetup:      func(m *MockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Invalid token",
+			requestBody: models.VerifyEmailRequest{Token: "abc"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("VerifyEmail", mock.Anything, mock.AnythingOfType("*models.VerifyEmailRequest")).Return(services.ErrInvalidVerificationToken)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAuthHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/verify-email", handler.VerifyEmail)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/verify-email", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_ResendVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		userIDExists   bool
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:         "Positive: Verification email sent",
+			userIDExists: true,
+			mockSetup: func(m *MockAuthService) {
+				m.On("ResendVerification", mock.Anything, uint(1)).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: User not authenticated",
+			userIDExists:   false,
+			mockSetup:      func(m *MockAuthService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Negative: Already verified",
+			userIDExists: true,
+			mockSetup: func(m *MockAuthService) {
+				m.On("ResendVerification", mock.Anything, uint(1)).Return(services.ErrEmailAlreadyVerified)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:         "Negative: Throttled",
+			userIDExists: true,
+			mockSetup: func(m *MockAuthService) {
+				m.On("ResendVerification", mock.Anything, uint(1)).Return(services.ErrVerificationThrottled)
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAuthHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/resend-verification", func(c *gin.Context) {
+				if tt.userIDExists {
+					c.Set("userID", uint(1))
+				}
+				handler.ResendVerification(c)
+			})
+
+			req := httptest.NewRequest("POST", "/resend-verification", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_VerifyTwoFactorLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Valid code",
+			requestBody: models.TwoFactorLoginRequest{ChallengeToken: "challenge", Code: "123456"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("VerifyTwoFactorLogin", mock.Anything, mock.AnythingOfType("*models.TwoFactorLoginRequest"), mock.AnythingOfType("string")).Return(&models.LoginResponse{Token: "token123"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Missing code",
+			requestBody:    map[string]string{"challenge_token": "challenge"},
+			mockSetup:      func(m *MockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Invalid code",
+			requestBody: models.TwoFactorLoginRequest{ChallengeToken: "challenge", Code: "000000"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("VerifyTwoFactorLogin", mock.Anything, mock.AnythingOfType("*models.TwoFactorLoginRequest"), mock.AnythingOfType("string")).Return(nil, services.ErrInvalidTwoFactorCode)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "Negative: Expired challenge",
+			requestBody: models.TwoFactorLoginRequest{ChallengeToken: "expired", Code: "123456"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("VerifyTwoFactorLogin", mock.Anything, mock.AnythingOfType("*models.TwoFactorLoginRequest"), mock.AnythingOfType("string")).Return(nil, services.ErrInvalidChallenge)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAuthHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/login/2fa", handler.VerifyTwoFactorLogin)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/login/2fa", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_EnrollTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name: "Positive: Enrollment started",
+			mockSetup: func(m *MockAuthService) {
+				m.On("EnrollTwoFactor", mock.Anything, uint(1)).Return(&models.TwoFactorEnrollResponse{Secret: "SECRET", ProvisioningURI: "otpauth://totp/test"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Negative: Already enabled",
+			mockSetup: func(m *MockAuthService) {
+				m.On("EnrollTwoFactor", mock.Anything, uint(1)).Return(nil, services.ErrTwoFactorAlreadyEnabled)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAuthHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/2fa/enroll", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.EnrollTwoFactor(c)
+			})
+
+			req := httptest.NewRequest("POST", "/2fa/enroll", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_ConfirmTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		mockSetup      func(*MockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Valid code",
+			requestBody: models.TwoFactorCodeRequest{Code: "123456"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("ConfirmTwoFactor", mock.Anything, uint(1), mock.AnythingOfType("*models.TwoFactorCodeRequest")).Return(&models.TwoFactorConfirmResponse{RecoveryCodes: []string{"code1"}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "Negative: Invalid code",
+			requestBody: models.TwoFactorCodeRequest{Code: "000000"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("ConfirmTwoFactor", mock.Anything, uint(1), mock.AnythingOfType("*models.TwoFactorCodeRequest")).Return(nil, services.ErrInvalidTwoFactorCode)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Not enrolled",
+			requestBody: models.TwoFactorCodeRequest{Code: "123456"},
+			mockSetup: func(m *MockAuthService) {
+				m.On("ConfirmTwoFactor", mock.Anything, uint(1), mock.AnythingOfType("*models.TwoFactorCodeRequest")).Return(nil, services.ErrTwoFactorNotEnrolled)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			tt.mockSetup(mockService)
+
+			handler := NewAuthHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/2fa/confirm", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.ConfirmTwoFactor(c)
+			})
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/2fa/confirm", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestNewAuthHandler(t *testing.T) {
 	mockService := new(MockAuthService)
 	handler := NewAuthHandler(mockService)
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type DataExportHandler struct {
+	exportService services.DataExportService
+}
+
+func NewDataExportHandler(exportService services.DataExportService) *DataExportHandler {
+	return &DataExportHandler{exportService}
+}
+
+// RequestExport streams the archive when it could be built immediately and
+// otherwise answers 202 with a status URL to poll.
+func (h *DataExportHandler) RequestExport(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	export, err := h.exportService.RequestExport(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch export.Status {
+	case models.DataExportStatusReady:
+		c.FileAttachment(export.FilePath, exportFileName(export))
+	case models.DataExportStatusFailed:
+		c.Error(fmt.Errorf("export %s failed: %s", export.ID, export.Error))
+	default:
+		c.JSON(http.StatusAccepted, toDataExportResponse(export))
+	}
+}
+
+func (h *DataExportHandler) GetExport(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	export, err := h.exportService.GetExport(c.Request.Context(), userID.(uint), c.Param("exportID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toDataExportResponse(export))
+}
+
+func (h *DataExportHandler) DownloadExport(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	export, err := h.exportService.GetExport(c.Request.Context(), userID.(uint), c.Param("exportID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if export.Status != models.DataExportStatusReady {
+		c.Error(services.ErrExportNotReady)
+		return
+	}
+
+	c.FileAttachment(export.FilePath, exportFileName(export))
+}
+
+func toDataExportResponse(export *models.DataExport) models.DataExportResponse {
+	resp := models.DataExportResponse{
+		ID:          export.ID,
+		Status:      export.Status,
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+		ExpiresAt:   export.ExpiresAt,
+	}
+	if export.Status == models.DataExportStatusReady {
+		resp.DownloadURL = fmt.Sprintf("/api/v1/profile/exports/%s/download", export.ID)
+	}
+	return resp
+}
+
+func exportFileName(export *models.DataExport) string {
+	return fmt.Sprintf("personal-data-%s.zip", export.CreatedAt.Format("20060102"))
+}
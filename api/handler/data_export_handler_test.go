@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDataExportService struct {
+	mock.Mock
+}
+
+func (m *MockDataExportService) RequestExport(ctx context.Context, userID uint) (*models.DataExport, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DataExport), args.Error(1)
+}
+
+func (m *MockDataExportService) GetExport(ctx context.Context, userID uint, exportID string) (*models.DataExport, error) {
+	args := m.Called(ctx, userID, exportID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DataExport), args.Error(1)
+}
+
+func (m *MockDataExportService) RemoveExpired(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockDataExportService) StartCleanupJob(ctx context.Context) {
+	m.Called(ctx)
+}
+
+func TestDataExportHandler_RequestExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	archivePath := filepath.Join(t.TempDir(), "export.zip")
+	os.WriteFile(archivePath, []byte("zip"), 0o600)
+
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockDataExportService)
+		expectedStatus int
+	}{
+		{
+			name: "Positive: Small account is downloaded directly",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("RequestExport", mock.Anything, uint(1)).Return(&models.DataExport{ID: "exp1", UserID: 1, Status: models.DataExportStatusReady, FilePath: archivePath, CreatedAt: time.Now()}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Positive: Large account is processed in background",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("RequestExport", mock.Anything, uint(1)).Return(&models.DataExport{ID: "exp1", UserID: 1, Status: models.DataExportStatusPending}, nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "Negative: Export already pending",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("RequestExport", mock.Anything, uint(1)).Return(nil, services.ErrExportPending)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "Negative: Export requested recently",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("RequestExport", mock.Anything, uint(1)).Return(nil, services.ErrExportThrottled.WithRetryAfter(time.Hour))
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name: "Negative: Service error",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("RequestExport", mock.Anything, uint(1)).Return(nil, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockDataExportService)
+			tt.mockSetup(mockService)
+
+			handler := NewDataExportHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/profile/export", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.RequestExport(c)
+			})
+
+			req := httptest.NewRequest("GET", "/profile/export", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDataExportHandler_GetExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockDataExportService)
+		expectedStatus int
+	}{
+		{
+			name: "Positive: Export status",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("GetExport", mock.Anything, uint(1), "exp1").Return(&models.DataExport{ID: "exp1", UserID: 1, Status: models.DataExportStatusProcessing}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Negative: Export of another user",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("GetExport", mock.Anything, uint(1), "exp1").Return(nil, services.ErrExportNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "Negative: Export expired",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("GetExport", mock.Anything, uint(1), "exp1").Return(nil, services.ErrExportExpired)
+			},
+			expectedStatus: http.StatusGone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockDataExportService)
+			tt.mockSetup(mockService)
+
+			handler := NewDataExportHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/profile/exports/:exportID", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.GetExport(c)
+			})
+
+			req := httptest.NewRequest("GET", "/profile/exports/exp1", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDataExportHandler_DownloadExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	archivePath := filepath.Join(t.TempDir(), "export.zip")
+	os.WriteFile(archivePath, []byte("zip"), 0o600)
+
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockDataExportService)
+		expectedStatus int
+	}{
+		{
+			name: "Positive: Ready export",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("GetExport", mock.Anything, uint(1), "exp1").Return(&models.DataExport{ID: "exp1", UserID: 1, Status: models.DataExportStatusReady, FilePath: archivePath}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Negative: Export still processing",
+			mockSetup: func(m *MockDataExportService) {
+				m.On("GetExport", mock.Anything, uint(1), "exp1").Return(&models.DataExport{ID: "exp1", UserID: 1, Status: models.DataExportStatusProcessing}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockDataExportService)
+			tt.mockSetup(mockService)
+
+			handler := NewDataExportHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/profile/exports/:exportID/download", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.DownloadExport(c)
+			})
+
+			req := httptest.NewRequest("GET", "/profile/exports/exp1/download", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package handler
+
+import "github.com/gin-gonic/gin"
+
+// bindError hands a request that could not be bound to ErrorMiddleware, which
+// answers 400 and lists the invalid fields.
+func bindError(c *gin.Context, err error) {
+	c.Error(err).SetType(gin.ErrorTypeBind)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthCheckTimeout bounds each readiness check, probes are usually cut off
+// after a few seconds anyway.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck is one dependency the server needs to take traffic.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	checks []HealthCheck
+}
+
+func NewHealthHandler(checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{checks}
+}
+
+// Live reports that the process is up and serving requests. It checks
+// nothing else, so a database outage does not get the server restarted.
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready runs every check and answers 503 if one of them fails, so the load
+// balancer stops routing requests here until it recovers.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	status := http.StatusOK
+	results := make(gin.H, len(h.checks))
+	for _, check := range h.checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+		err := check.Check(ctx)
+		cancel()
+
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			results[check.Name] = err.Error()
+			continue
+		}
+		results[check.Name] = "ok"
+	}
+
+	overall := "ok"
+	if status != http.StatusOK {
+		overall = "unavailable"
+	}
+	c.JSON(status, gin.H{"status": overall, "checks": results})
+}
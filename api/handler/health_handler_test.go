@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler_Live(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	failing := HealthCheck{Name: "database", Check: func(ctx context.Context) error { return errors.New("connection refused") }}
+	handler := NewHealthHandler(failing)
+	router := gin.New()
+	router.GET("/health/live", handler.Live)
+
+	req := httptest.NewRequest("GET", "/health/live", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHealthHandler_Ready(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ok := func(ctx context.Context) error { return nil }
+
+	tests := []struct {
+		name           string
+		checks         []HealthCheck
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:           "Positive: All checks pass",
+			checks:         []HealthCheck{{Name: "database", Check: ok}, {Name: "midtrans", Check: ok}},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"status": "ok",
+				"checks": map[string]interface{}{"database": "ok", "midtrans": "ok"},
+			},
+		},
+		{
+			name: "Negative: One check fails",
+			checks: []HealthCheck{
+				{Name: "database", Check: ok},
+				{Name: "midtrans", Check: func(ctx context.Context) error { return errors.New("midtrans responded with status 503") }},
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody: map[string]interface{}{
+				"status": "unavailable",
+				"checks": map[string]interface{}{"database": "ok", "midtrans": "midtrans responded with status 503"},
+			},
+		},
+		{
+			name: "Negative: Check runs into the timeout",
+			checks: []HealthCheck{{Name: "database", Check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}}},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody: map[string]interface{}{
+				"status": "unavailable",
+				"checks": map[string]interface{}{"database": context.DeadlineExceeded.Error()},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(tt.checks...)
+			router := gin.New()
+			router.GET("/health/ready", handler.Ready)
+
+			req := httptest.NewRequest("GET", "/health/ready", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	notificationService services.NotificationService
+}
+
+func NewNotificationHandler(notificationService services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService}
+}
+
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	prefs, err := h.notificationService.GetPreferences(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	var req models.NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	prefs, err := h.notificationService.UpdatePreferences(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
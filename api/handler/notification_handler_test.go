@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockNotificationService struct {
+	mock.Mock
+}
+
+func (m *MockNotificationService) NotifyPaymentStatus(ctx context.Context, tx *models.Transaction) {
+	m.Called(ctx, tx)
+}
+
+func (m *MockNotificationService) GetPreferences(ctx context.Context, userID uint) (*models.NotificationPreferencesResponse, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.NotificationPreferencesResponse), args.Error(1)
+}
+
+func (m *MockNotificationService) UpdatePreferences(ctx context.Context, userID uint, req *models.NotificationPreferencesRequest) (*models.NotificationPreferencesResponse, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.NotificationPreferencesResponse), args.Error(1)
+}
+
+func TestNotificationHandler_GetPreferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		setUser        bool
+		mockSetup      func(*MockNotificationService)
+		expectedStatus int
+	}{
+		{
+			name:    "Positive: Preferences returned",
+			setUser: true,
+			mockSetup: func(m *MockNotificationService) {
+				m.On("GetPreferences", mock.Anything, uint(1)).Return(&models.NotificationPreferencesResponse{Language: "id", PaymentSuccess: true}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: User not authenticated",
+			setUser:        false,
+			mockSetup:      func(m *MockNotificationService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "Negative: Service error",
+			setUser: true,
+			mockSetup: func(m *MockNotificationService) {
+				m.On("GetPreferences", mock.Anything, uint(1)).Return(nil, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			tt.mockSetup(mockService)
+
+			handler := NewNotificationHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/profile/notifications", func(c *gin.Context) {
+				if tt.setUser {
+					c.Set("userID", uint(1))
+				}
+				handler.GetPreferences(c)
+			})
+
+			req := httptest.NewRequest("GET", "/profile/notifications", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNotificationHandler_UpdatePreferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		setUser        bool
+		body           string
+		mockSetup      func(*MockNotificationService)
+		expectedStatus int
+	}{
+		{
+			name:    "Positive: Preferences updated",
+			setUser: true,
+			body:    `{"language":"en","payment_failed":false}`,
+			mockSetup: func(m *MockNotificationService) {
+				m.On("UpdatePreferences", mock.Anything, uint(1), mock.AnythingOfType("*models.NotificationPreferencesRequest")).Return(&models.NotificationPreferencesResponse{Language: "en", PaymentSuccess: true}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: User not authenticated",
+			setUser:        false,
+			body:           `{"language":"en"}`,
+			mockSetup:      func(m *MockNotificationService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Negative: Unsupported language",
+			setUser:        true,
+			body:           `{"language":"fr"}`,
+			mockSetup:      func(m *MockNotificationService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Negative: Invalid JSON",
+			setUser:        true,
+			body:           `{"payment_success":`,
+			mockSetup:      func(m *MockNotificationService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Negative: Service error",
+			setUser: true,
+			body:    `{"payment_success":false}`,
+			mockSetup: func(m *MockNotificationService) {
+				m.On("UpdatePreferences", mock.Anything, uint(1), mock.AnythingOfType("*models.NotificationPreferencesRequest")).Return(nil, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			tt.mockSetup(mockService)
+
+			handler := NewNotificationHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.PATCH("/profile/notifications", func(c *gin.Context) {
+				if tt.setUser {
+					c.Set("userID", uint(1))
+				}
+				handler.UpdatePreferences(c)
+			})
+
+			req := httptest.NewRequest("PATCH", "/profile/notifications", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentEventsHandler struct {
+	paymentService services.PaymentService
+	hub            services.EventHub
+	heartbeat      time.Duration
+}
+
+func NewPaymentEventsHandler(paymentService services.PaymentService, hub services.EventHub, heartbeat time.Duration) *PaymentEventsHandler {
+	return &PaymentEventsHandler{paymentService, hub, heartbeat}
+}
+
+// StreamStatus pushes the status of one transaction as Server-Sent Events.
+// The current status is sent first, followed by every change until the
+// client disconnects. If the client cannot keep up the stream is closed and
+// the client is expected to reconnect.
+func (h *PaymentEventsHandler) StreamStatus(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	orderID := c.Param("orderID")
+
+	// note : subscribe before loading the transaction so no change between
+	// the two can get lost
+	sub := h.hub.Subscribe(func(event models.PaymentEvent) bool {
+		return event.OrderID == orderID
+	})
+	defer h.hub.Unsubscribe(sub)
+
+	transaction, err := h.paymentService.GetPaymentStatus(c.Request.Context(), orderID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if transaction.UserID != userID.(uint) {
+		c.Error(services.ErrTransactionForbidden)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.SSEvent("status", models.NewPaymentEvent(models.PaymentEventUpdated, transaction))
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			c.SSEvent("status", event)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// signallingHub exposes every subscription made through it so tests can
+// publish only once the handler is listening.
+type signallingHub struct {
+	services.EventHub
+	subscribed chan *services.EventSubscription
+}
+
+func (h *signallingHub) Subscribe(filter func(models.PaymentEvent) bool) *services.EventSubscription {
+	sub := h.EventHub.Subscribe(filter)
+	h.subscribed <- sub
+	return sub
+}
+
+func TestPaymentEventsHandler_StreamStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		setUser        bool
+		mockSetup      func(*MockPaymentService)
+		publish        bool
+		expectedStatus int
+		expectedBody   []string
+	}{
+		{
+			name:    "Positive: Streams current status and updates",
+			setUser: true,
+			mockSetup: func(m *MockPaymentService) {
+				m.On("GetPaymentStatus", mock.Anything, "order-123").Return(&models.Transaction{ID: "order-123", UserID: 1, Status: "pending"}, nil)
+			},
+			publish:        true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{"event:status", `"status":"pending"`, `"status":"success"`},
+		},
+		{
+			name:           "Negative: User not authenticated",
+			setUser:        false,
+			mockSetup:      func(m *MockPaymentService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "Negative: Transaction not found",
+			setUser: true,
+			mockSetup: func(m *MockPaymentService) {
+				m.On("GetPaymentStatus", mock.Anything, "order-123").Return(nil, services.ErrTransactionNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:    "Negative: Transaction of another user",
+			setUser: true,
+			mockSetup: func(m *MockPaymentService) {
+				m.On("GetPaymentStatus", mock.Anything, "order-123").Return(&models.Transaction{ID: "order-123", UserID: 2, Status: "pending"}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockPaymentService)
+			tt.mockSetup(mockService)
+
+			hub := &signallingHub{EventHub: services.NewEventHub(), subscribed: make(chan *services.EventSubscription, 1)}
+			handler := NewPaymentEventsHandler(mockService, hub, time.Hour)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/payments/:orderID/events", func(c *gin.Context) {
+				if tt.setUser {
+					c.Set("userID", uint(1))
+				}
+				handler.StreamStatus(c)
+			})
+
+			req := httptest.NewRequest("GET", "/payments/order-123/events", nil)
+			w := httptest.NewRecorder()
+
+			if tt.publish {
+				go func() {
+					sub := <-hub.subscribed
+					hub.Publish(models.PaymentEvent{Type: models.PaymentEventUpdated, OrderID: "order-123", UserID: 1, Status: "success"})
+					hub.Publish(models.PaymentEvent{Type: models.PaymentEventUpdated, OrderID: "order-456", UserID: 1, Status: "failed"})
+					// closing the subscription ends the stream once the
+					// buffered event has been written
+					hub.Unsubscribe(sub)
+				}()
+			}
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			for _, expected := range tt.expectedBody {
+				assert.Contains(t, w.Body.String(), expected)
+			}
+			if tt.publish {
+				assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"))
+				assert.False(t, strings.Contains(w.Body.String(), "order-456"))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	feedWriteWait      = 10 * time.Second
+	feedMaxMessageSize = 4096
+	// feedMaxOrders bounds the number of order IDs a single connection can
+	// subscribe to.
+	feedMaxOrders = 100
+)
+
+type PaymentFeedHandler struct {
+	hub          services.EventHub
+	userService  services.UserService
+	pingInterval time.Duration
+	upgrader     websocket.Upgrader
+}
+
+func NewPaymentFeedHandler(hub services.EventHub, userService services.UserService, pingInterval time.Duration, allowedOrigins []string) *PaymentFeedHandler {
+	return &PaymentFeedHandler{
+		hub:          hub,
+		userService:  userService,
+		pingInterval: pingInterval,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{middleware.WebSocketTokenProtocol},
+			CheckOrigin:  checkOrigin(allowedOrigins),
+		},
+	}
+}
+
+// checkOrigin allows every origin when no list is configured. The feed is
+// authenticated with a token rather than cookies, so a foreign page cannot
+// open it on behalf of a user.
+func checkOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		if len(allowedOrigins) == 0 {
+			return true
+		}
+		origin := r.Header.Get("Origin")
+		for _, allowed := range allowedOrigins {
+			if origin == allowed {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// feedFilter tracks which orders a connection listens to. It starts out
+// receiving every event in scope; subscribing to order IDs narrows the feed to
+// those orders and subscribing without IDs widens it again.
+type feedFilter struct {
+	mu     sync.Mutex
+	all    bool
+	orders map[string]bool
+}
+
+func (f *feedFilter) matches(orderID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.all || f.orders[orderID]
+}
+
+func (f *feedFilter) apply(req *models.PaymentFeedRequest) models.PaymentFeedReply {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch req.Action {
+	case models.PaymentFeedActionSubscribe:
+		if len(req.OrderIDs) == 0 {
+			f.all = true
+			f.orders = map[string]bool{}
+			break
+		}
+		if len(f.orders)+len(req.OrderIDs) > feedMaxOrders {
+			return models.PaymentFeedReply{Type: "error", Error: "too many subscribed orders"}
+		}
+		f.all = false
+		for _, orderID := range req.OrderIDs {
+			f.orders[orderID] = true
+		}
+	case models.PaymentFeedActionUnsubscribe:
+		if len(req.OrderIDs) == 0 {
+			f.all = false
+			f.orders = map[string]bool{}
+			break
+		}
+		for _, orderID := range req.OrderIDs {
+			delete(f.orders, orderID)
+		}
+	default:
+		return models.PaymentFeedReply{Type: "error", Error: "unknown action"}
+	}
+
+	orderIDs := make([]string, 0, len(f.orders))
+	for orderID := range f.orders {
+		orderIDs = append(orderIDs, orderID)
+	}
+	sort.Strings(orderIDs)
+	return models.PaymentFeedReply{Type: "subscription", All: f.all, OrderIDs: orderIDs}
+}
+
+// Serve upgrades the request to a WebSocket that carries transaction created
+// and updated events of the caller, or of all users for admins. Clients that
+// do not keep up with the feed are disconnected.
+func (h *PaymentFeedHandler) Serve(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	user, err := h.userService.GetUserByID(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	isAdmin := user.Role == models.RoleAdmin
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	filter := &feedFilter{all: true, orders: map[string]bool{}}
+	sub := h.hub.Subscribe(func(event models.PaymentEvent) bool {
+		return (isAdmin || event.UserID == user.ID) && filter.matches(event.OrderID)
+	})
+	defer h.hub.Unsubscribe(sub)
+
+	replies := make(chan models.PaymentFeedReply, 8)
+	done := make(chan struct{})
+	go h.readLoop(conn, filter, replies, done)
+
+	ping := time.NewTicker(h.pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+				if errors.Is(sub.Err(), services.ErrEventHubClosed) {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				}
+				conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(feedWriteWait))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(feedWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case reply := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(feedWriteWait))
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(feedWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *PaymentFeedHandler) readLoop(conn *websocket.Conn, filter *feedFilter, replies chan<- models.PaymentFeedReply, done chan<- struct{}) {
+	defer close(done)
+
+	pongWait := 2 * h.pingInterval
+	conn.SetReadLimit(feedMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		reply := models.PaymentFeedReply{Type: "error", Error: "invalid message"}
+		var req models.PaymentFeedRequest
+		if err := json.Unmarshal(message, &req); err == nil {
+			reply = filter.apply(&req)
+		}
+
+		select {
+		case replies <- reply:
+		default:
+			// note : the client keeps sending without reading the replies
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newPaymentFeedServer(t *testing.T, userService services.UserService, hub services.EventHub, setUser bool) *httptest.Server {
+	handler := NewPaymentFeedHandler(hub, userService, time.Minute, nil)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.GET("/payments/feed", func(c *gin.Context) {
+		if setUser {
+			c.Set("userID", uint(1))
+		}
+		handler.Serve(c)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dialPaymentFeed(t *testing.T, server *httptest.Server) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/payments/feed"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestPaymentFeedHandler_Serve(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Positive: User receives own events only", func(t *testing.T) {
+		mockService := new(MockUserService)
+		mockService.On("GetUserByID", mock.Anything, uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+		hub := &signallingHub{EventHub: services.NewEventHub(), subscribed: make(chan *services.EventSubscription, 1)}
+
+		conn := dialPaymentFeed(t, newPaymentFeedServer(t, mockService, hub, true))
+		<-hub.subscribed
+
+		hub.Publish(models.PaymentEvent{Type: models.PaymentEventCreated, OrderID: "order-2", UserID: 2, Status: "pending"})
+		hub.Publish(models.PaymentEvent{Type: models.PaymentEventCreated, OrderID: "order-1", UserID: 1, Status: "pending"})
+
+		var event models.PaymentEvent
+		require.NoError(t, conn.ReadJSON(&event))
+		assert.Equal(t, "order-1", event.OrderID)
+		assert.Equal(t, models.PaymentEventCreated, event.Type)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Positive: Admin receives events of all users", func(t *testing.T) {
+		mockService := new(MockUserService)
+		mockService.On("GetUserByID", mock.Anything, uint(1)).Return(&models.User{ID: 1, Role: models.RoleAdmin}, nil)
+		hub := &signallingHub{EventHub: services.NewEventHub(), subscribed: make(chan *services.EventSubscription, 1)}
+
+		conn := dialPaymentFeed(t, newPaymentFeedServer(t, mockService, hub, true))
+		<-hub.subscribed
+
+		hub.Publish(models.PaymentEvent{Type: models.PaymentEventUpdated, OrderID: "order-2", UserID: 2, Status: "success"})
+
+		var event models.PaymentEvent
+		require.NoError(t, conn.ReadJSON(&event))
+		assert.Equal(t, "order-2", event.OrderID)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Positive: Subscribe and unsubscribe by order ID", func(t *testing.T) {
+		mockService := new(MockUserService)
+		mockService.On("GetUserByID", mock.Anything, uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+		hub := &signallingHub{EventHub: services.NewEventHub(), subscribed: make(chan *services.EventSubscription, 1)}
+
+		conn := dialPaymentFeed(t, newPaymentFeedServer(t, mockService, hub, true))
+		<-hub.subscribed
+
+		require.NoError(t, conn.WriteJSON(models.PaymentFeedRequest{Action: models.PaymentFeedActionSubscribe, OrderIDs: []string{"order-b", "order-a"}}))
+		var reply models.PaymentFeedReply
+		require.NoError(t, conn.ReadJSON(&reply))
+		assert.Equal(t, "subscription", reply.Type)
+		assert.False(t, reply.All)
+		assert.Equal(t, []string{"order-a", "order-b"}, reply.OrderIDs)
+
+		require.NoError(t, conn.WriteJSON(models.PaymentFeedRequest{Action: models.PaymentFeedActionUnsubscribe, OrderIDs: []string{"order-a"}}))
+		require.NoError(t, conn.ReadJSON(&reply))
+		assert.Equal(t, []string{"order-b"}, reply.OrderIDs)
+
+		hub.Publish(models.PaymentEvent{Type: models.PaymentEventUpdated, OrderID: "order-a", UserID: 1, Status: "success"})
+		hub.Publish(models.PaymentEvent{Type: models.PaymentEventUpdated, OrderID: "order-c", UserID: 1, Status: "success"})
+		hub.Publish(models.PaymentEvent{Type: models.PaymentEventUpdated, OrderID: "order-b", UserID: 1, Status: "success"})
+
+		var event models.PaymentEvent
+		require.NoError(t, conn.ReadJSON(&event))
+		assert.Equal(t, "order-b", event.OrderID)
+	})
+
+	t.Run("Negative: Unknown action", func(t *testing.T) {
+		mockService := new(MockUserService)
+		mockService.On("GetUserByID", mock.Anything, uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+		hub := &signallingHub{EventHub: services.NewEventHub(), subscribed: make(chan *services.EventSubscription, 1)}
+
+		conn := dialPaymentFeed(t, newPaymentFeedServer(t, mockService, hub, true))
+		<-hub.subscribed
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"shout"}`)))
+		var reply models.PaymentFeedReply
+		require.NoError(t, conn.ReadJSON(&reply))
+		assert.Equal(t, "error", reply.Type)
+	})
+
+	t.Run("Negative: User not authenticated", func(t *testing.T) {
+		mockService := new(MockUserService)
+		hub := services.NewEventHub()
+
+		server := newPaymentFeedServer(t, mockService, hub, false)
+		resp, err := http.Get(server.URL + "/payments/feed")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Negative: User not found", func(t *testing.T) {
+		mockService := new(MockUserService)
+		mockService.On("GetUserByID", mock.Anything, uint(1)).Return(nil, services.ErrUserNotFound)
+		hub := services.NewEventHub()
+
+		server := newPaymentFeedServer(t, mockService, hub, true)
+		resp, err := http.Get(server.URL + "/payments/feed")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		mockService.AssertExpectations(t)
+	})
+}
@@ -1,9 +1,10 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
 	"github.com/gin-gonic/gin"
@@ -18,49 +19,53 @@ func NewPaymentHandler(paymentService services.PaymentService, userService servi
 	return &PaymentHandler{paymentService, userService}
 }
 
+// withLogAttrs adds args to the request scoped logger, and so to the access
+// log line of the request.
+func withLogAttrs(c *gin.Context, args ...any) {
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), args...))
+}
+
 func (h *PaymentHandler) CreatePayment(c *gin.Context) {
 	var req models.CreatePaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(middleware.ErrNotAuthenticated)
 		return
 	}
 
-	user, err := h.userService.GetUserByID(userID.(uint))
+	user, err := h.userService.GetUserByID(c.Request.Context(), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Authenticated user not found"})
+		c.Error(err)
 		return
 	}
 
-	resp, err := h.paymentService.CreatePayment(&req, user)
+	resp, err := h.paymentService.CreatePayment(c.Request.Context(), &req, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to process after payment creation",
-			"details": fmt.Sprintf("%v", err),
-		})
+		c.Error(err)
 		return
 	}
 
+	withLogAttrs(c, "order_id", resp.OrderID)
 	c.JSON(http.StatusOK, resp)
 }
 
 func (h *PaymentHandler) GetStatus(c *gin.Context) {
 	orderID := c.Param("orderID")
 
-	transaction, err := h.paymentService.GetPaymentStatus(orderID)
+	transaction, err := h.paymentService.GetPaymentStatus(c.Request.Context(), orderID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		c.Error(err)
 		return
 	}
 
 	userID, _ := c.Get("userID")
 	if transaction.UserID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to view this transaction"})
+		c.Error(services.ErrTransactionForbidden)
 		return
 	}
 
@@ -70,13 +75,19 @@ func (h *PaymentHandler) GetStatus(c *gin.Context) {
 func (h *PaymentHandler) GetHistory(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	var query models.PaymentHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindError(c, err)
 		return
 	}
 
-	history, err := h.paymentService.GetPaymentHistory(userID.(uint))
+	history, err := h.paymentService.GetPaymentHistory(c.Request.Context(), userID.(uint), &query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment history", "details": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -86,13 +97,16 @@ func (h *PaymentHandler) GetHistory(c *gin.Context) {
 func (h *PaymentHandler) HandleNotification(c *gin.Context) {
 	var notificationPayload map[string]interface{}
 	if err := c.ShouldBindJSON(&notificationPayload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification payload"})
+		bindError(c, err)
 		return
 	}
+	if orderID, ok := notificationPayload["order_id"].(string); ok {
+		withLogAttrs(c, "order_id", orderID)
+	}
 
-	err := h.paymentService.HandleNotification(notificationPayload)
+	err := h.paymentService.HandleNotification(c.Request.Context(), notificationPayload)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to handle notification", "details": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -102,21 +116,22 @@ func (h *PaymentHandler) HandleNotification(c *gin.Context) {
 func (h *PaymentHandler) CreateQrisPayment(c *gin.Context) {
 	var req models.CreateQrisPaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
 	userID := c.MustGet("userID").(uint)
-	user, err := h.userService.GetUserByID(userID)
+	user, err := h.userService.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Authenticated user not found"})
+		c.Error(err)
 		return
 	}
-	resp, err := h.paymentService.CreateQrisPayment(&req, user)
+	resp, err := h.paymentService.CreateQrisPayment(c.Request.Context(), &req, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create QRIS payment", "details": fmt.Sprintf("%v", err)})
+		c.Error(err)
 		return
 	}
 
+	withLogAttrs(c, "order_id", resp.OrderID)
 	c.JSON(http.StatusOK, resp)
 }
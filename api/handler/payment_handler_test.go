@@ -2,13 +2,17 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -18,43 +22,59 @@ type MockPaymentService struct {
 	mock.Mock
 }
 
-func (m *MockPaymentService) CreatePayment(req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error) {
-	args := m.Called(req, user)
+func (m *MockPaymentService) CreatePayment(ctx context.Context, req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error) {
+	args := m.Called(ctx, req, user)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.CreatePaymentResponse), args.Error(1)
 }
 
-func (m *MockPaymentService) GetPaymentStatus(orderID string) (*models.Transaction, error) {
-	args := m.Called(orderID)
+func (m *MockPaymentService) GetPaymentStatus(ctx context.Context, orderID string) (*models.Transaction, error) {
+	args := m.Called(ctx, orderID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Transaction), args.Error(1)
 }
 
-func (m *MockPaymentService) GetPaymentHistory(userID uint) ([]models.Transaction, error) {
-	args := m.Called(userID)
+func (m *MockPaymentService) GetPaymentHistory(ctx context.Context, userID uint, query *models.PaymentHistoryQuery) (*models.PaymentHistoryResponse, error) {
+	args := m.Called(ctx, userID, query)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]models.Transaction), args.Error(1)
+	return args.Get(0).(*models.PaymentHistoryResponse), args.Error(1)
 }
 
-func (m *MockPaymentService) HandleNotification(payload map[string]interface{}) error {
-	args := m.Called(payload)
+func (m *MockPaymentService) HandleNotification(ctx context.Context, payload map[string]interface{}) error {
+	args := m.Called(ctx, payload)
 	return args.Error(0)
 }
 
-func (m *MockPaymentService) CreateQrisPayment(req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error) {
-	args := m.Called(req, user)
+func (m *MockPaymentService) CreateQrisPayment(ctx context.Context, req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error) {
+	args := m.Called(ctx, req, user)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.CreateQrisPaymentResponse), args.Error(1)
 }
 
+func (m *MockPaymentService) ReplayNotification(ctx context.Context, orderID string) (*models.Transaction, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+func (m *MockPaymentService) Reconcile(ctx context.Context, since time.Time) (*models.ReconcileResult, error) {
+	args := m.Called(ctx, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ReconcileResult), args.Error(1)
+}
+
 func TestPaymentHandler_CreatePayment(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -78,11 +98,28 @@ func TestPaymentHandler_CreatePayment(t *testing.T) {
 			userIDExists: true,
 			mockSetup: func(mp *MockPaymentService, mu *MockUserService) {
 				user := &models.User{ID: 1, FullName: "Test User"}
-				mu.On("GetUserByID", uint(1)).Return(user, nil)
-				mp.On("CreatePayment", mock.AnythingOfType("*models.CreatePaymentRequest"), user).Return(&models.CreatePaymentResponse{OrderID: "order123"}, nil)
+				mu.On("GetUserByID", mock.Anything, uint(1)).Return(user, nil)
+				mp.On("CreatePayment", mock.Anything, mock.AnythingOfType("*models.CreatePaymentRequest"), user).Return(&models.CreatePaymentResponse{OrderID: "order123"}, nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name: "Negative: Email not verified",
+			requestBody: models.CreatePaymentRequest{
+				Items: []models.ItemDetailRequest{{ID: "1", Name: "Test", Price: 1000, Quantity: 1}},
+				CustomerDetails: models.AddressDetail{
+					FirstName: "Test", Email: "test@example.com", Phone: "123", Address: "Test", City: "Test", PostalCode: "12345",
+				},
+			},
+			userID:       uint(1),
+			userIDExists: true,
+			mockSetup: func(mp *MockPaymentService, mu *MockUserService) {
+				user := &models.User{ID: 1, FullName: "Test User"}
+				mu.On("GetUserByID", mock.Anything, uint(1)).Return(user, nil)
+				mp.On("CreatePayment", mock.Anything, mock.AnythingOfType("*models.CreatePaymentRequest"), user).Return(nil, services.ErrEmailNotVerified)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
 		{
 			name:           "Negative: Invalid JSON",
 			requestBody:    "invalid",
@@ -113,6 +150,7 @@ func TestPaymentHandler_CreatePayment(t *testing.T) {
 
 			handler := NewPaymentHandler(mockPaymentService, mockUserService)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.POST("/payment", func(c *gin.Context) {
 				if tt.userIDExists {
 					c.Set("userID", tt.userID)
@@ -156,7 +194,7 @@ func TestPaymentHandler_GetStatus(t *testing.T) {
 			userID:  1,
 			mockSetup: func(m *MockPaymentService) {
 				transaction := &models.Transaction{ID: "order123", UserID: 1, Status: "pending"}
-				m.On("GetPaymentStatus", "order123").Return(transaction, nil)
+				m.On("GetPaymentStatus", mock.Anything, "order123").Return(transaction, nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -165,7 +203,7 @@ func TestPaymentHandler_GetStatus(t *testing.T) {
 			orderID: "notfound",
 			userID:  1,
 			mockSetup: func(m *MockPaymentService) {
-				m.On("GetPaymentStatus", "notfound").Return(nil, errors.New("not found"))
+				m.On("GetPaymentStatus", mock.Anything, "notfound").Return(nil, services.ErrTransactionNotFound)
 			},
 			expectedStatus: http.StatusNotFound,
 		},
@@ -175,7 +213,7 @@ func TestPaymentHandler_GetStatus(t *testing.T) {
 			userID:  2,
 			mockSetup: func(m *MockPaymentService) {
 				transaction := &models.Transaction{ID: "order123", UserID: 1, Status: "pending"}
-				m.On("GetPaymentStatus", "order123").Return(transaction, nil)
+				m.On("GetPaymentStatus", mock.Anything, "order123").Return(transaction, nil)
 			},
 			expectedStatus: http.StatusForbidden,
 		},
@@ -188,6 +226,7 @@ func TestPaymentHandler_GetStatus(t *testing.T) {
 
 			handler := NewPaymentHandler(mockService, nil)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.GET("/status/:orderID", func(c *gin.Context) {
 				c.Set("userID", tt.userID)
 				handler.GetStatus(c)
@@ -211,6 +250,7 @@ func TestPaymentHandler_GetHistory(t *testing.T) {
 		name           string
 		userIDExists   bool
 		userID         uint
+		queryString    string
 		mockSetup      func(*MockPaymentService)
 		expectedStatus int
 	}{
@@ -219,11 +259,49 @@ func TestPaymentHandler_GetHistory(t *testing.T) {
 			userIDExists: true,
 			userID:       1,
 			mockSetup: func(m *MockPaymentService) {
-				history := []models.Transaction{{ID: "order1", UserID: 1}}
-				m.On("GetPaymentHistory", uint(1)).Return(history, nil)
+				history := &models.PaymentHistoryResponse{Data: []models.TransactionResponse{{ID: "order1"}}, TotalCount: 1, Limit: 20}
+				m.On("GetPaymentHistory", mock.Anything, uint(1), mock.AnythingOfType("*models.PaymentHistoryQuery")).Return(history, nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:         "Positive: Filters are bound",
+			userIDExists: true,
+			userID:       1,
+			queryString:  "?status=success&status=pending&from=2025-01-01&to=2025-01-31&min_amount=1000&sort=amount_desc&limit=10",
+			mockSetup: func(m *MockPaymentService) {
+				m.On("GetPaymentHistory", mock.Anything, uint(1), mock.MatchedBy(func(q *models.PaymentHistoryQuery) bool {
+					return len(q.Status) == 2 && q.From.Day() == 1 && q.To.Day() == 31 && *q.MinAmount == 1000 && q.Sort == models.HistorySortAmountDesc && q.Limit == 10
+				})).Return(&models.PaymentHistoryResponse{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Invalid sort",
+			userIDExists:   true,
+			userID:         1,
+			queryString:    "?sort=random",
+			mockSetup:      func(m *MockPaymentService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Negative: Limit too large",
+			userIDExists:   true,
+			userID:         1,
+			queryString:    "?limit=1000",
+			mockSetup:      func(m *MockPaymentService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "Negative: Invalid cursor",
+			userIDExists: true,
+			userID:       1,
+			queryString:  "?cursor=garbage",
+			mockSetup: func(m *MockPaymentService) {
+				m.On("GetPaymentHistory", mock.Anything, uint(1), mock.AnythingOfType("*models.PaymentHistoryQuery")).Return(nil, services.ErrInvalidCursor)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Negative: User not authenticated",
 			userIDExists:   false,
@@ -235,7 +313,7 @@ func TestPaymentHandler_GetHistory(t *testing.T) {
 			userIDExists: true,
 			userID:       1,
 			mockSetup: func(m *MockPaymentService) {
-				m.On("GetPaymentHistory", uint(1)).Return(nil, errors.New("service error"))
+				m.On("GetPaymentHistory", mock.Anything, uint(1), mock.AnythingOfType("*models.PaymentHistoryQuery")).Return(nil, errors.New("service error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 		},
@@ -248,6 +326,7 @@ func TestPaymentHandler_GetHistory(t *testing.T) {
 
 			handler := NewPaymentHandler(mockService, nil)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.GET("/history", func(c *gin.Context) {
 				if tt.userIDExists {
 					c.Set("userID", tt.userID)
@@ -255,7 +334,7 @@ func TestPaymentHandler_GetHistory(t *testing.T) {
 				handler.GetHistory(c)
 			})
 
-			req := httptest.NewRequest("GET", "/history", nil)
+			req := httptest.NewRequest("GET", "/history"+tt.queryString, nil)
 			w := httptest.NewRecorder()
 
 			router.ServeHTTP(w, req)
@@ -279,7 +358,7 @@ func TestPaymentHandler_HandleNotification(t *testing.T) {
 			name:        "Positive: Valid notification",
 			requestBody: map[string]interface{}{"order_id": "order123", "transaction_status": "settlement"},
 			mockSetup: func(m *MockPaymentService) {
-				m.On("HandleNotification", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+				m.On("HandleNotification", mock.Anything, mock.AnythingOfType("map[string]interface {}")).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -293,10 +372,18 @@ func TestPaymentHandler_HandleNotification(t *testing.T) {
 			name:        "Negative: Service error",
 			requestBody: map[string]interface{}{"order_id": "order123"},
 			mockSetup: func(m *MockPaymentService) {
-				m.On("HandleNotification", mock.AnythingOfType("map[string]interface {}")).Return(errors.New("service error"))
+				m.On("HandleNotification", mock.Anything, mock.AnythingOfType("map[string]interface {}")).Return(errors.New("service error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 		},
+		{
+			name:        "Negative: Invalid signature",
+			requestBody: map[string]interface{}{"order_id": "order123", "signature_key": "forged"},
+			mockSetup: func(m *MockPaymentService) {
+				m.On("HandleNotification", mock.Anything, mock.AnythingOfType("map[string]interface {}")).Return(services.ErrInvalidSignature)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
 	}
 
 	for _, tt := range tests {
@@ -306,6 +393,7 @@ func TestPaymentHandler_HandleNotification(t *testing.T) {
 
 			handler := NewPaymentHandler(mockService, nil)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.POST("/notification", handler.HandleNotification)
 
 			var body []byte
@@ -345,11 +433,24 @@ func TestPaymentHandler_CreateQrisPayment(t *testing.T) {
 			userID: 1,
 			mockSetup: func(mp *MockPaymentService, mu *MockUserService) {
 				user := &models.User{ID: 1, FullName: "Test User"}
-				mu.On("GetUserByID", uint(1)).Return(user, nil)
-				mp.On("CreateQrisPayment", mock.AnythingOfType("*models.CreateQrisPaymentRequest"), user).Return(&models.CreateQrisPaymentResponse{OrderID: "qris123"}, nil)
+				mu.On("GetUserByID", mock.Anything, uint(1)).Return(user, nil)
+				mp.On("CreateQrisPayment", mock.Anything, mock.AnythingOfType("*models.CreateQrisPaymentRequest"), user).Return(&models.CreateQrisPaymentResponse{OrderID: "qris123"}, nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name: "Negative: Email not verified",
+			requestBody: models.CreateQrisPaymentRequest{
+				Items: []models.ItemDetailRequest{{ID: "1", Name: "Test", Price: 1000, Quantity: 1}},
+			},
+			userID: 1,
+			mockSetup: func(mp *MockPaymentService, mu *MockUserService) {
+				user := &models.User{ID: 1, FullName: "Test User"}
+				mu.On("GetUserByID", mock.Anything, uint(1)).Return(user, nil)
+				mp.On("CreateQrisPayment", mock.Anything, mock.AnythingOfType("*models.CreateQrisPaymentRequest"), user).Return(nil, services.ErrEmailNotVerified)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
 		{
 			name:           "Negative: Invalid JSON",
 			requestBody:    "invalid",
@@ -367,6 +468,7 @@ func TestPaymentHandler_CreateQrisPayment(t *testing.T) {
 
 			handler := NewPaymentHandler(mockPaymentService, mockUserService)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.POST("/qris", func(c *gin.Context) {
 				c.Set("userID", tt.userID)
 				handler.CreateQrisPayment(c)
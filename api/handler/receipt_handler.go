@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReceiptHandler struct {
+	receiptService services.ReceiptService
+}
+
+func NewReceiptHandler(receiptService services.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{receiptService}
+}
+
+func (h *ReceiptHandler) DownloadReceipt(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	orderID := c.Param("orderID")
+	receipt, err := h.receiptService.GenerateReceipt(c.Request.Context(), userID.(uint), orderID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="receipt-%s.pdf"`, orderID))
+	c.Data(http.StatusOK, "application/pdf", receipt)
+}
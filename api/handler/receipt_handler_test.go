@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReceiptService struct {
+	mock.Mock
+}
+
+func (m *MockReceiptService) GenerateReceipt(ctx context.Context, userID uint, orderID string) ([]byte, error) {
+	args := m.Called(ctx, userID, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func TestReceiptHandler_DownloadReceipt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		setUser        bool
+		mockSetup      func(*MockReceiptService)
+		expectedStatus int
+		expectedType   string
+	}{
+		{
+			name:    "Positive: Receipt generated",
+			setUser: true,
+			mockSetup: func(m *MockReceiptService) {
+				m.On("GenerateReceipt", mock.Anything, uint(1), "order-123").Return([]byte("%PDF-1.4"), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/pdf",
+		},
+		{
+			name:           "Negative: User not authenticated",
+			setUser:        false,
+			mockSetup:      func(m *MockReceiptService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "Negative: Transaction not found",
+			setUser: true,
+			mockSetup: func(m *MockReceiptService) {
+				m.On("GenerateReceipt", mock.Anything, uint(1), "order-123").Return(nil, services.ErrTransactionNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:    "Negative: Transaction not successful",
+			setUser: true,
+			mockSetup: func(m *MockReceiptService) {
+				m.On("GenerateReceipt", mock.Anything, uint(1), "order-123").Return(nil, services.ErrReceiptUnavailable)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:    "Negative: Service error",
+			setUser: true,
+			mockSetup: func(m *MockReceiptService) {
+				m.On("GenerateReceipt", mock.Anything, uint(1), "order-123").Return(nil, errors.New("render error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockReceiptService)
+			tt.mockSetup(mockService)
+
+			handler := NewReceiptHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/payments/:orderID/receipt", func(c *gin.Context) {
+				if tt.setUser {
+					c.Set("userID", uint(1))
+				}
+				handler.DownloadReceipt(c)
+			})
+
+			req := httptest.NewRequest("GET", "/payments/order-123/receipt", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedType != "" {
+				assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	reportService services.ReportService
+}
+
+func NewReportHandler(reportService services.ReportService) *ReportHandler {
+	return &ReportHandler{reportService}
+}
+
+func (h *ReportHandler) GetDailyReport(c *gin.Context) {
+	var query models.ReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	report, err := h.reportService.GetDailyReport(c.Request.Context(), &query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *ReportHandler) GetSummary(c *gin.Context) {
+	var query models.ReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	summary, err := h.reportService.GetSummary(c.Request.Context(), &query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReportService struct {
+	mock.Mock
+}
+
+func (m *MockReportService) GetDailyReport(ctx context.Context, query *models.ReportQuery) (*models.DailyReportResponse, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DailyReportResponse), args.Error(1)
+}
+
+func (m *MockReportService) GetSummary(ctx context.Context, query *models.ReportQuery) (*models.ReportSummaryResponse, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ReportSummaryResponse), args.Error(1)
+}
+
+func (m *MockReportService) MaterializeDay(ctx context.Context, day time.Time) error {
+	args := m.Called(ctx, day)
+	return args.Error(0)
+}
+
+func (m *MockReportService) StartNightlyJob(ctx context.Context) {
+	m.Called(ctx)
+}
+
+func TestReportHandler_GetDailyReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		query          string
+		mockSetup      func(*MockReportService)
+		expectedStatus int
+	}{
+		{
+			name:  "Positive: Daily report",
+			query: "?from=2024-01-01&to=2024-01-31",
+			mockSetup: func(m *MockReportService) {
+				m.On("GetDailyReport", mock.Anything, mock.AnythingOfType("*models.ReportQuery")).Return(&models.DailyReportResponse{From: "2024-01-01", To: "2024-01-31"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Missing range",
+			query:          "?from=2024-01-01",
+			mockSetup:      func(m *MockReportService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Negative: Invalid date",
+			query:          "?from=01-01-2024&to=2024-01-31",
+			mockSetup:      func(m *MockReportService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Negative: Invalid range",
+			query: "?from=2024-02-01&to=2024-01-01",
+			mockSetup: func(m *MockReportService) {
+				m.On("GetDailyReport", mock.Anything, mock.AnythingOfType("*models.ReportQuery")).Return(nil, services.ErrInvalidReportRange)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Negative: Service error",
+			query: "?from=2024-01-01&to=2024-01-31",
+			mockSetup: func(m *MockReportService) {
+				m.On("GetDailyReport", mock.Anything, mock.AnythingOfType("*models.ReportQuery")).Return(nil, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockReportService)
+			tt.mockSetup(mockService)
+
+			handler := NewReportHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/admin/reports/daily", handler.GetDailyReport)
+
+			req := httptest.NewRequest("GET", "/admin/reports/daily"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReportHandler_GetSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		query          string
+		mockSetup      func(*MockReportService)
+		expectedStatus int
+	}{
+		{
+			name:  "Positive: Summary",
+			query: "?from=2024-01-01&to=2024-01-31",
+			mockSetup: func(m *MockReportService) {
+				m.On("GetSummary", mock.Anything, mock.AnythingOfType("*models.ReportQuery")).Return(&models.ReportSummaryResponse{From: "2024-01-01", To: "2024-01-31"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Missing range",
+			query:          "",
+			mockSetup:      func(m *MockReportService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Negative: Service error",
+			query: "?from=2024-01-01&to=2024-01-31",
+			mockSetup: func(m *MockReportService) {
+				m.On("GetSummary", mock.Anything, mock.AnythingOfType("*models.ReportQuery")).Return(nil, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockReportService)
+			tt.mockSetup(mockService)
+
+			handler := NewReportHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/admin/reports/summary", handler.GetSummary)
+
+			req := httptest.NewRequest("GET", "/admin/reports/summary"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
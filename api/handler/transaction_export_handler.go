@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type TransactionExportHandler struct {
+	exportService services.TransactionExportService
+}
+
+func NewTransactionExportHandler(exportService services.TransactionExportService) *TransactionExportHandler {
+	return &TransactionExportHandler{exportService}
+}
+
+func (h *TransactionExportHandler) ExportTransactions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Error(middleware.ErrNotAuthenticated)
+		return
+	}
+
+	var query models.TransactionExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	id := userID.(uint)
+	query.UserID = &id
+	h.stream(c, &query)
+}
+
+// AdminExportTransactions exports transactions of all users, or of a single
+// user when user_id is given.
+func (h *TransactionExportHandler) AdminExportTransactions(c *gin.Context) {
+	var query models.TransactionExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	h.stream(c, &query)
+}
+
+func (h *TransactionExportHandler) stream(c *gin.Context, query *models.TransactionExportQuery) {
+	if query.Format == "" {
+		query.Format = models.ExportFormatCSV
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if query.Format == models.ExportFormatXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	fileName := fmt.Sprintf("transactions-%s.%s", time.Now().Format("20060102-150405"), query.Format)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Status(http.StatusOK)
+
+	if err := h.exportService.Export(c.Request.Context(), c.Writer, query); err != nil {
+		c.Error(err)
+		if c.Writer.Written() {
+			// note : the download already started, the client gets a truncated file
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTransactionExportService struct {
+	mock.Mock
+}
+
+func (m *MockTransactionExportService) Export(ctx context.Context, w io.Writer, query *models.TransactionExportQuery) error {
+	args := m.Called(ctx, w, query)
+	if args.Error(0) == nil {
+		io.WriteString(w, "order_id\n")
+	}
+	return args.Error(0)
+}
+
+func TestTransactionExportHandler_ExportTransactions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name                string
+		queryString         string
+		mockSetup           func(*MockTransactionExportService)
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{
+			name:        "Positive: CSV export is scoped to the caller",
+			queryString: "?user_id=2&status=success",
+			mockSetup: func(m *MockTransactionExportService) {
+				m.On("Export", mock.Anything, mock.Anything, mock.MatchedBy(func(q *models.TransactionExportQuery) bool {
+					return *q.UserID == 1 && q.Format == models.ExportFormatCSV && q.Status[0] == "success"
+				})).Return(nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+		},
+		{
+			name:        "Positive: XLSX export",
+			queryString: "?format=xlsx",
+			mockSetup: func(m *MockTransactionExportService) {
+				m.On("Export", mock.Anything, mock.Anything, mock.AnythingOfType("*models.TransactionExportQuery")).Return(nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		},
+		{
+			name:           "Negative: Unsupported format",
+			queryString:    "?format=pdf",
+			mockSetup:      func(m *MockTransactionExportService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Negative: Failure before streaming",
+			mockSetup: func(m *MockTransactionExportService) {
+				m.On("Export", mock.Anything, mock.Anything, mock.AnythingOfType("*models.TransactionExportQuery")).Return(errors.New("db error"))
+			},
+			expectedStatus:      http.StatusInternalServerError,
+			expectedContentType: "application/json; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockTransactionExportService)
+			tt.mockSetup(mockService)
+
+			handler := NewTransactionExportHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.GET("/payments/export", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.ExportTransactions(c)
+			})
+
+			req := httptest.NewRequest("GET", "/payments/export"+tt.queryString, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedContentType != "" {
+				assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTransactionExportHandler_AdminExportTransactions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockTransactionExportService)
+	mockService.On("Export", mock.Anything, mock.Anything, mock.MatchedBy(func(q *models.TransactionExportQuery) bool {
+		return q.UserID != nil && *q.UserID == 7
+	})).Return(nil)
+
+	handler := NewTransactionExportHandler(mockService)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.GET("/admin/payments/export", handler.AdminExportTransactions)
+
+	req := httptest.NewRequest("GET", "/admin/payments/export?user_id=7", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	mockService.AssertExpectations(t)
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -17,14 +22,35 @@ type MockUserService struct {
 	mock.Mock
 }
 
-func (m *MockUserService) GetUserByID(id uint) (*models.User, error) {
-	args := m.Called(id)
+func (m *MockUserService) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockUserService) UpdateProfile(ctx context.Context, id uint, req *models.UpdateProfileRequest) (*models.User, error) {
+	args := m.Called(ctx, id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) ChangePassword(ctx context.Context, id uint, req *models.ChangePasswordRequest, clientIP string) (*models.ChangePasswordResponse, error) {
+	args := m.Called(ctx, id, req, clientIP)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ChangePasswordResponse), args.Error(1)
+}
+
+func (m *MockUserService) DeleteAccount(ctx context.Context, id uint, req *models.DeleteAccountRequest, clientIP string) error {
+	args := m.Called(ctx, id, req, clientIP)
+	return args.Error(0)
+}
+
 func TestUserHandler_GetProfile(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -47,7 +73,7 @@ func TestUserHandler_GetProfile(t *testing.T) {
 					Email:    "test@example.com",
 					Password: "hashedpassword",
 				}
-				m.On("GetUserByID", uint(1)).Return(user, nil)
+				m.On("GetUserByID", mock.Anything, uint(1)).Return(user, nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -63,7 +89,7 @@ func TestUserHandler_GetProfile(t *testing.T) {
 			userID:       uint(999),
 			userIDExists: true,
 			mockSetup: func(m *MockUserService) {
-				m.On("GetUserByID", uint(999)).Return(nil, errors.New("user not found"))
+				m.On("GetUserByID", mock.Anything, uint(999)).Return(nil, services.ErrUserNotFound)
 			},
 			expectedStatus: http.StatusNotFound,
 		},
@@ -83,6 +109,7 @@ func TestUserHandler_GetProfile(t *testing.T) {
 
 			handler := NewUserHandler(mockService)
 			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
 			router.GET("/profile", func(c *gin.Context) {
 				if tt.userIDExists {
 					c.Set("userID", tt.userID)
@@ -127,10 +154,11 @@ func TestUserHandler_GetProfile_PasswordClearing(t *testing.T) {
 		FullName: "Test User",
 		Password: "shouldbecleared",
 	}
-	mockService.On("GetUserByID", uint(1)).Return(user, nil)
+	mockService.On("GetUserByID", mock.Anything, uint(1)).Return(user, nil)
 
 	handler := NewUserHandler(mockService)
 	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
 	router.GET("/profile", func(c *gin.Context) {
 		c.Set("userID", uint(1))
 		handler.GetProfile(c)
@@ -146,6 +174,201 @@ func TestUserHandler_GetProfile_PasswordClearing(t *testing.T) {
 	assert.Equal(t, "Test User", response.FullName)
 }
 
+func TestUserHandler_UpdateProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockUserService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Update city",
+			requestBody: `{"city":"Bandung"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("UpdateProfile", mock.Anything, uint(1), mock.AnythingOfType("*models.UpdateProfileRequest")).Return(&models.User{ID: 1, City: "Bandung", Password: "hashed"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Non numeric postal code",
+			requestBody:    `{"postal_code":"abcde"}`,
+			mockSetup:      func(m *MockUserService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Invalid phone number",
+			requestBody: `{"phone_number":"call-me-maybe"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("UpdateProfile", mock.Anything, uint(1), mock.AnythingOfType("*models.UpdateProfileRequest")).Return(nil, services.ErrInvalidPhoneNumber)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockUserService)
+			tt.mockSetup(mockService)
+
+			handler := NewUserHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.PATCH("/profile", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.UpdateProfile(c)
+			})
+
+			req := httptest.NewRequest("PATCH", "/profile", strings.NewReader(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response models.User
+				json.Unmarshal(w.Body.Bytes(), &response)
+				assert.Empty(t, response.Password)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockUserService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Password changed",
+			requestBody: `{"current_password":"oldpassword","new_password":"newpassword"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("ChangePassword", mock.Anything, uint(1), mock.AnythingOfType("*models.ChangePasswordRequest"), mock.AnythingOfType("string")).Return(&models.ChangePasswordResponse{Token: "token123"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: New password too short",
+			requestBody:    `{"current_password":"oldpassword","new_password":"123"}`,
+			mockSetup:      func(m *MockUserService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Wrong current password",
+			requestBody: `{"current_password":"wrong","new_password":"newpassword"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("ChangePassword", mock.Anything, uint(1), mock.AnythingOfType("*models.ChangePasswordRequest"), mock.AnythingOfType("string")).Return(nil, services.ErrIncorrectPassword)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Account locked",
+			requestBody: `{"current_password":"oldpassword","new_password":"newpassword"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("ChangePassword", mock.Anything, uint(1), mock.AnythingOfType("*models.ChangePasswordRequest"), mock.AnythingOfType("string")).Return(nil, services.ErrLoginThrottled.WithRetryAfter(time.Minute))
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockUserService)
+			tt.mockSetup(mockService)
+
+			handler := NewUserHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.POST("/profile/password", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.ChangePassword(c)
+			})
+
+			req := httptest.NewRequest("POST", "/profile/password", strings.NewReader(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserHandler_DeleteAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockSetup      func(*MockUserService)
+		expectedStatus int
+	}{
+		{
+			name:        "Positive: Account deleted",
+			requestBody: `{"password":"password123"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("DeleteAccount", mock.Anything, uint(1), mock.AnythingOfType("*models.DeleteAccountRequest"), mock.AnythingOfType("string")).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Negative: Missing password",
+			requestBody:    `{}`,
+			mockSetup:      func(m *MockUserService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Wrong password",
+			requestBody: `{"password":"wrong"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("DeleteAccount", mock.Anything, uint(1), mock.AnythingOfType("*models.DeleteAccountRequest"), mock.AnythingOfType("string")).Return(services.ErrIncorrectPassword)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Negative: Service error",
+			requestBody: `{"password":"password123"}`,
+			mockSetup: func(m *MockUserService) {
+				m.On("DeleteAccount", mock.Anything, uint(1), mock.AnythingOfType("*models.DeleteAccountRequest"), mock.AnythingOfType("string")).Return(errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockUserService)
+			tt.mockSetup(mockService)
+
+			handler := NewUserHandler(mockService)
+			router := gin.New()
+			router.Use(middleware.ErrorMiddleware())
+			router.DELETE("/profile", func(c *gin.Context) {
+				c.Set("userID", uint(1))
+				handler.DeleteAccount(c)
+			})
+
+			req := httptest.NewRequest("DELETE", "/profile", strings.NewReader(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestNewUserHandler(t *testing.T) {
 	mockService := new(MockUserService)
 	handler := NewUserHandler(mockService)
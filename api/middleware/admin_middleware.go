@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+var errAdminRequired = apperror.Forbidden("ADMIN_REQUIRED", "admin access required")
+
+// AdminMiddleware must run after AuthMiddleware. It rejects users that do not
+// have the admin role.
+func AdminMiddleware(userService services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.Error(ErrNotAuthenticated)
+			c.Abort()
+			return
+		}
+
+		user, err := userService.GetUserByID(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if user.Role != models.RoleAdmin {
+			c.Error(errAdminRequired)
+			c.Abort()
+			return
+		}
+
+		c.Set("userRole", user.Role)
+		c.Next()
+	}
+}
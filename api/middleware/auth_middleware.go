@@ -1,38 +1,74 @@
 package middleware
 
 import (
-	"net/http"
 	"strings"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
+// WebSocketTokenProtocol is the subprotocol browsers use to pass the JWT when
+// opening a WebSocket, since they cannot set the Authorization header:
+// new WebSocket(url, ["bearer", token]).
+const WebSocketTokenProtocol = "bearer"
+
+var (
+	// ErrNotAuthenticated is reported by handlers that find no user set by
+	// AuthMiddleware.
+	ErrNotAuthenticated     = apperror.Unauthorized("UNAUTHENTICATED", "authentication required")
+	errInvalidAuthorization = apperror.Unauthorized("INVALID_AUTHORIZATION_HEADER", "authorization header format must be Bearer {token}")
+)
+
 func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			authHeader = webSocketAuthHeader(c)
+		}
+		if authHeader == "" {
+			c.Error(ErrNotAuthenticated)
 			c.Abort()
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
+			c.Error(errInvalidAuthorization)
 			c.Abort()
 			return
 		}
 
 		tokenString := parts[1]
-		userID, err := authService.ValidateToken(tokenString)
+		userID, err := authService.ValidateToken(c.Request.Context(), tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
+			c.Error(err)
 			c.Abort()
 			return
 		}
 
 		c.Set("userID", userID)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", userID))
 		c.Next()
 	}
 }
+
+func webSocketAuthHeader(c *gin.Context) string {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return ""
+	}
+
+	var protocols []string
+	for _, header := range c.Request.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == WebSocketTokenProtocol {
+			return "Bearer " + protocols[i+1]
+		}
+	}
+	return ""
+}
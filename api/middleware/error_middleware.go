@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	errInternal       = apperror.New(apperror.KindInternal, "INTERNAL_ERROR", "internal server error")
+	errInvalidRequest = apperror.Validation("INVALID_REQUEST", "invalid request")
+	errRouteNotFound  = apperror.NotFound("ROUTE_NOT_FOUND", "route not found")
+)
+
+var kindStatus = map[apperror.Kind]int{
+	apperror.KindInternal:        http.StatusInternalServerError,
+	apperror.KindValidation:      http.StatusBadRequest,
+	apperror.KindUnauthorized:    http.StatusUnauthorized,
+	apperror.KindForbidden:       http.StatusForbidden,
+	apperror.KindNotFound:        http.StatusNotFound,
+	apperror.KindConflict:        http.StatusConflict,
+	apperror.KindGone:            http.StatusGone,
+	apperror.KindTooManyRequests: http.StatusTooManyRequests,
+	apperror.KindUnavailable:     http.StatusServiceUnavailable,
+}
+
+// ErrorMiddleware writes the response for the last error a handler added with
+// c.Error. An *apperror.Error decides status, code and message, errors of the
+// gin.ErrorTypeBind type become 400 with the offending fields, and anything
+// else is answered with a generic 500 so SQL or gateway errors never reach the
+// client. The full error is still logged by LoggerMiddleware.
+func ErrorMiddleware() gin.HandlerFunc {
+	useJSONFieldNames()
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+		appErr := errInternal
+		if last.IsType(gin.ErrorTypeBind) {
+			appErr = bindError(last.Err)
+		} else {
+			errors.As(last.Err, &appErr)
+		}
+		writeError(c, appErr)
+	}
+}
+
+// NotFoundHandler answers unknown routes with the error envelope.
+func NotFoundHandler(c *gin.Context) {
+	c.Error(errRouteNotFound)
+}
+
+func writeError(c *gin.Context, appErr *apperror.Error) {
+	status, ok := kindStatus[appErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	resp := models.ErrorResponse{
+		Error:     appErr.Message,
+		Code:      appErr.Code,
+		Fields:    appErr.Fields,
+		RequestID: c.GetString("requestID"),
+	}
+	if appErr.RetryAfter > 0 {
+		resp.RetryAfter = ceilSeconds(appErr.RetryAfter)
+		c.Header("Retry-After", strconv.Itoa(resp.RetryAfter))
+	}
+	c.AbortWithStatusJSON(status, resp)
+}
+
+// bindError describes what is wrong with a request that could not be bound,
+// without echoing decoder internals.
+func bindError(err error) *apperror.Error {
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var numErr *strconv.NumError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]apperror.FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, apperror.FieldError{Field: fieldErr.Field(), Message: validationMessage(fieldErr)})
+		}
+		return apperror.Validation("VALIDATION_FAILED", "request validation failed", fields...)
+	case errors.Is(err, io.EOF):
+		return apperror.Validation("INVALID_REQUEST", "request body is empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apperror.Validation("INVALID_REQUEST", "request body is not valid JSON")
+	case errors.As(err, &typeErr):
+		return apperror.Validation("INVALID_REQUEST", "request body has a field of the wrong type",
+			apperror.FieldError{Field: typeErr.Field, Message: "must be a " + typeErr.Type.Kind().String()})
+	case errors.As(err, &numErr):
+		return apperror.Validation("INVALID_REQUEST", "query parameter must be a number")
+	default:
+		return errInvalidRequest
+	}
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	case "min", "gte":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+		}
+		if fieldErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at least %s items", fieldErr.Param())
+		}
+		return "must be at least " + fieldErr.Param()
+	case "max", "lte":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+		}
+		if fieldErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at most %s items", fieldErr.Param())
+		}
+		return "must be at most " + fieldErr.Param()
+	case "gt":
+		return "must be greater than " + fieldErr.Param()
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters long", fieldErr.Param())
+	default:
+		return "is invalid"
+	}
+}
+
+var registerFieldNames sync.Once
+
+// useJSONFieldNames makes validation errors name fields as clients send them,
+// by their json or form tag, instead of by the Go field name.
+func useJSONFieldNames() {
+	registerFieldNames.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return field.Name
+		})
+	})
+}
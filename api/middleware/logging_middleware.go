@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// note : incoming IDs end up in every log line of the request, so only short
+// IDs of safe characters are kept
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware keeps the X-Request-ID sent by the client or a proxy,
+// or assigns a new one, echoes it in the response and puts a logger carrying
+// it into the request context. The trace ID is added when the request is
+// traced.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			generated, err := utils.GenerateRandomToken(16)
+			if err != nil {
+				generated = fmt.Sprintf("%d", time.Now().UnixNano())
+			}
+			requestID = generated
+		}
+
+		c.Set("requestID", requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		args := []any{"request_id", requestID}
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+			args = append(args, "trace_id", spanContext.TraceID().String())
+		}
+		if orderID := c.Param("orderID"); orderID != "" {
+			args = append(args, "order_id", orderID)
+		}
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), args...))
+		c.Next()
+	}
+}
+
+// LoggerMiddleware writes one access log line per request with the request
+// scoped logger, so the user and order added by later handlers are included.
+// The query string is left out since it can carry tokens.
+func LoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate); len(errs) > 0 {
+			attrs = append(attrs, slog.String("error", errs.String()))
+		}
+
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// RecoveryMiddleware turns panics into a 500 response and logs them with the
+// request scoped logger instead of gin's plain text output.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logging.FromContext(c.Request.Context()).Error("panic recovered",
+					"panic", fmt.Sprint(recovered),
+					"stack", string(debug.Stack()),
+				)
+				if c.Writer.Written() {
+					c.Abort()
+					return
+				}
+				writeError(c, errInternal)
+			}
+		}()
+		c.Next()
+	}
+}
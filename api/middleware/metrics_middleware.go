@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware records the count and latency of requests per route
+// pattern, so order IDs in paths do not create new series.
+func MetricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		m.ObserveHTTPRequest(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
+
+var errInvalidMetricsToken = apperror.Unauthorized("INVALID_METRICS_TOKEN", "invalid metrics token")
+
+// MetricsAuthMiddleware protects /metrics with a static bearer token when one
+// is configured.
+func MetricsAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+			c.Error(errInvalidMetricsToken)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
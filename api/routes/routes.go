@@ -4,45 +4,105 @@ import (
 	"github.com/bagussubagja/backend-payment-gateway-go/api/handler"
 	"github.com/bagussubagja/backend-payment-gateway-go/api/middleware"
 	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/metrics"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/ratelimit"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func SetupRouter(authSvc services.AuthService, userSvc services.UserService, paymentSvc services.PaymentService, cfg *config.Config) *gin.Engine {
-	r := gin.Default()
+func SetupRouter(authSvc services.AuthService, userSvc services.UserService, paymentSvc services.PaymentService, exportSvc services.DataExportService, txExportSvc services.TransactionExportService, reportSvc services.ReportService, receiptSvc services.ReceiptService, notificationSvc services.NotificationService, eventHub services.EventHub, appMetrics *metrics.Metrics, limiter ratelimit.Store, readyChecks []handler.HealthCheck, cfg *config.Config) *gin.Engine {
+	r := gin.New()
+	// note : gin trusts every proxy by default, so X-Forwarded-For is only
+	// honoured from the configured ones. Entries are validated by
+	// config.LoadConfig, an empty list makes ClientIP the remote address.
+	r.SetTrustedProxies(cfg.TrustedProxies)
+	r.Use(otelgin.Middleware(cfg.TracingServiceName), middleware.RequestIDMiddleware(), middleware.LoggerMiddleware(), middleware.RecoveryMiddleware(), middleware.MetricsMiddleware(appMetrics), middleware.ErrorMiddleware())
+	r.NoRoute(middleware.NotFoundHandler)
 
 	entryHandler := handler.NewEntryHandler()
+	healthHandler := handler.NewHealthHandler(readyChecks...)
 	authHandler := handler.NewAuthHandler(authSvc)
 	userHandler := handler.NewUserHandler(userSvc)
 	paymentHandler := handler.NewPaymentHandler(paymentSvc, userSvc)
+	adminHandler := handler.NewAdminHandler(authSvc)
+	exportHandler := handler.NewDataExportHandler(exportSvc)
+	txExportHandler := handler.NewTransactionExportHandler(txExportSvc)
+	reportHandler := handler.NewReportHandler(reportSvc)
+	receiptHandler := handler.NewReceiptHandler(receiptSvc)
+	notificationHandler := handler.NewNotificationHandler(notificationSvc)
+	paymentEventsHandler := handler.NewPaymentEventsHandler(paymentSvc, eventHub, cfg.SSEHeartbeatInterval)
+	paymentFeedHandler := handler.NewPaymentFeedHandler(eventHub, userSvc, cfg.WSPingInterval, cfg.WSAllowedOrigins)
+
+	registerLimit := middleware.RateLimitMiddleware(limiter, middleware.RateLimitPolicy{Name: "register", Limit: cfg.RateLimitRegister, Key: middleware.RateLimitByIP})
+	loginLimit := middleware.RateLimitMiddleware(limiter, middleware.RateLimitPolicy{Name: "login", Limit: cfg.RateLimitLogin, Key: middleware.RateLimitByIP})
+	authLimit := middleware.RateLimitMiddleware(limiter, middleware.RateLimitPolicy{Name: "auth", Limit: cfg.RateLimitAuth, Key: middleware.RateLimitByIP})
+	notificationLimit := middleware.RateLimitMiddleware(limiter, middleware.RateLimitPolicy{Name: "notification", Limit: cfg.RateLimitNotification, Key: middleware.RateLimitByIP})
+	paymentLimit := middleware.RateLimitMiddleware(limiter, middleware.RateLimitPolicy{Name: "payments", Limit: cfg.RateLimitPayments, Key: middleware.RateLimitByUser})
+	apiLimit := middleware.RateLimitMiddleware(limiter, middleware.RateLimitPolicy{Name: "api", Limit: cfg.RateLimitAPI, Key: middleware.RateLimitByUser})
 
 	r.GET("/", entryHandler.GetEntry)
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
+	r.GET("/health/live", healthHandler.Live)
+	r.GET("/health/ready", healthHandler.Ready)
+	r.GET("/metrics", middleware.MetricsAuthMiddleware(cfg.MetricsToken), gin.WrapH(appMetrics.Handler()))
 
 	apiV1 := r.Group("/api/v1")
 	{
 		auth := apiV1.Group("/auth")
 		{
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/login", authHandler.Login)
+			auth.POST("/register", registerLimit, authHandler.Register)
+			auth.POST("/login", loginLimit, authHandler.Login)
+			auth.POST("/login/2fa", loginLimit, authHandler.VerifyTwoFactorLogin)
+			auth.POST("/forgot-password", authLimit, authHandler.ForgotPassword)
+			auth.POST("/reset-password", authLimit, authHandler.ResetPassword)
+			auth.POST("/verify-email", authLimit, authHandler.VerifyEmail)
+			auth.POST("/resend-verification", middleware.AuthMiddleware(authSvc), authHandler.ResendVerification)
 			auth.POST("/logout", middleware.AuthMiddleware(authSvc), authHandler.Logout)
 		}
 
-		apiV1.POST("/payments/notification", paymentHandler.HandleNotification)
+		apiV1.POST("/payments/notification", notificationLimit, paymentHandler.HandleNotification)
 	}
 
 	authorized := apiV1.Group("/")
-	authorized.Use(middleware.AuthMiddleware(authSvc))
+	authorized.Use(middleware.AuthMiddleware(authSvc), apiLimit)
 	{
 		authorized.GET("/profile", userHandler.GetProfile)
+		authorized.PATCH("/profile", userHandler.UpdateProfile)
+		authorized.DELETE("/profile", userHandler.DeleteAccount)
+		authorized.POST("/profile/password", userHandler.ChangePassword)
+		authorized.GET("/profile/notifications", notificationHandler.GetPreferences)
+		authorized.PATCH("/profile/notifications", notificationHandler.UpdatePreferences)
+		authorized.GET("/profile/export", exportHandler.RequestExport)
+		authorized.GET("/profile/exports/:exportID", exportHandler.GetExport)
+		authorized.GET("/profile/exports/:exportID/download", exportHandler.DownloadExport)
+		twoFactor := authorized.Group("/auth/2fa")
+		{
+			twoFactor.POST("/enroll", authHandler.EnrollTwoFactor)
+			twoFactor.POST("/confirm", authHandler.ConfirmTwoFactor)
+			twoFactor.POST("/disable", authHandler.DisableTwoFactor)
+		}
 		payments := authorized.Group("/payments")
 		{
-			payments.POST("/create", paymentHandler.CreatePayment)
+			payments.POST("/create", paymentLimit, paymentHandler.CreatePayment)
 			payments.GET("/status/:orderID", paymentHandler.GetStatus)
 			payments.GET("/history", paymentHandler.GetHistory)
-			payments.POST("/qris", paymentHandler.CreateQrisPayment)
+			payments.GET("/feed", paymentFeedHandler.Serve)
+			payments.GET("/export", txExportHandler.ExportTransactions)
+			payments.GET("/:orderID/receipt", receiptHandler.DownloadReceipt)
+			payments.GET("/:orderID/events", paymentEventsHandler.StreamStatus)
+			payments.POST("/qris", paymentLimit, paymentHandler.CreateQrisPayment)
+		}
+
+		admin := authorized.Group("/admin")
+		admin.Use(middleware.AdminMiddleware(userSvc))
+		{
+			admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
+			admin.GET("/payments/export", txExportHandler.AdminExportTransactions)
+			admin.GET("/reports/daily", reportHandler.GetDailyReport)
+			admin.GET("/reports/summary", reportHandler.GetSummary)
 		}
 
 	}
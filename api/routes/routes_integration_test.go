@@ -0,0 +1,1130 @@
+package routes_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/app"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/simulator"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"github.com/bagussubagja/backend-payment-gateway-go/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const testServerKey = "SB-Mid-server-integration"
+
+type testEnv struct {
+	server  *httptest.Server
+	sim     *simulator.Simulator
+	app     *app.App
+	mailDir string
+}
+
+// newTestEnv boots the real router on an in-memory SQLite database with the
+// Midtrans simulator in place of the gateway.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	sim := simulator.NewTestServer(t, simulator.Config{ServerKey: testServerKey})
+	mailDir := t.TempDir()
+
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_SQLITE_PATH", ":memory:")
+	t.Setenv("JWT_SECRET_KEY", "integration-secret")
+	t.Setenv("TOTP_ENCRYPTION_KEY", strings.Repeat("ab", 32))
+	t.Setenv("MIDTRANS_SERVER_KEY", testServerKey)
+	t.Setenv("MIDTRANS_CLIENT_KEY", "SB-Mid-client-integration")
+	t.Setenv("MIDTRANS_BASE_URL", sim.URL())
+	t.Setenv("MAIL_DRIVER", "file")
+	t.Setenv("MAIL_FILE_DIR", mailDir)
+	t.Setenv("EXPORT_DIR", t.TempDir())
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+
+	db, err := storage.NewDB(cfg)
+	require.NoError(t, err)
+	migrator, err := storage.NewMigrator(db)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	a, err := app.New(cfg, db)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(a.Router())
+	t.Cleanup(server.Close)
+	sim.SetNotificationURL(server.URL + "/api/v1/payments/notification")
+
+	return &testEnv{server: server, sim: sim, app: a, mailDir: mailDir}
+}
+
+func (e *testEnv) do(t *testing.T, method, path, token string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, e.server.URL+path, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+// mailContaining returns the first written mail that contains text.
+func (e *testEnv) mailContaining(t *testing.T, text string) string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(e.mailDir, "*.eml"))
+	require.NoError(t, err)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		if strings.Contains(string(content), text) {
+			return string(content)
+		}
+	}
+	return ""
+}
+
+var verifyTokenPattern = regexp.MustCompile(`verify-email\?token=([0-9a-f]+)`)
+
+// registerVerifiedUser registers a user, follows the verification mail and
+// logs in, returning the access token.
+func (e *testEnv) registerVerifiedUser(t *testing.T, username string) string {
+	t.Helper()
+
+	status := e.do(t, http.MethodPost, "/api/v1/auth/register", "", models.RegisterRequest{
+		FullName:    "Integration " + username,
+		Username:    username,
+		Email:       username + "@example.com",
+		Password:    "secret123",
+		Address:     "Jl. Sudirman 1",
+		PhoneNumber: "081234567890",
+		City:        "Jakarta",
+		PostalCode:  "10220",
+	}, nil)
+	require.Equal(t, http.StatusCreated, status)
+
+	mail := e.mailContaining(t, username+"@example.com")
+	match := verifyTokenPattern.FindStringSubmatch(mail)
+	require.NotNil(t, match, "verification mail not found")
+	status = e.do(t, http.MethodPost, "/api/v1/auth/verify-email", "", models.VerifyEmailRequest{Token: match[1]}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	status = e.do(t, http.MethodPost, "/api/v1/auth/login", "", models.LoginRequest{Username: username, Password: "secret123"}, &login)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, login.Token)
+	return login.Token
+}
+
+func paymentRequest() models.CreatePaymentRequest {
+	return models.CreatePaymentRequest{
+		Items: []models.ItemDetailRequest{
+			{ID: "PRD-001", Name: "Kopi Susu", Price: 18000, Quantity: 2},
+			{ID: "PRD-002", Name: "Roti Bakar", Price: 25000, Quantity: 1},
+		},
+		CustomerDetails: models.AddressDetail{
+			FirstName:  "Integration",
+			LastName:   "User",
+			Email:      "buyer@example.com",
+			Phone:      "081234567890",
+			Address:    "Jl. Sudirman 1",
+			City:       "Jakarta",
+			PostalCode: "10220",
+		},
+	}
+}
+
+func TestIntegration_PaymentFlow(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "buyer")
+
+	var created models.CreatePaymentResponse
+	status := env.do(t, http.MethodPost, "/api/v1/payments/create", token, paymentRequest(), &created)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, created.OrderID)
+	assert.True(t, strings.HasPrefix(created.RedirectURL, env.sim.URL()))
+
+	simTx, ok := env.sim.Transaction(created.OrderID)
+	require.True(t, ok, "transaction was not created at the gateway")
+	assert.Equal(t, int64(61000), simTx.GrossAmount)
+
+	var pending models.Transaction
+	status = env.do(t, http.MethodGet, "/api/v1/payments/status/"+created.OrderID, token, nil, &pending)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "pending", pending.Status)
+
+	// note : Settle returns once our notification endpoint has answered
+	require.NoError(t, env.sim.Settle(created.OrderID))
+
+	var settled models.Transaction
+	status = env.do(t, http.MethodGet, "/api/v1/payments/status/"+created.OrderID, token, nil, &settled)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "success", settled.Status)
+	assert.Equal(t, "bank_transfer", settled.PaymentType)
+	assert.NotNil(t, settled.SettledAt)
+	assert.Len(t, settled.Items, 2)
+
+	var history models.PaymentHistoryResponse
+	status = env.do(t, http.MethodGet, "/api/v1/payments/history", token, nil, &history)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, history.Data, 1)
+	assert.Equal(t, int64(1), history.TotalCount)
+	assert.Equal(t, created.OrderID, history.Data[0].ID)
+	assert.Equal(t, "success", history.Data[0].Status)
+	assert.Equal(t, int64(61000), history.Data[0].Amount)
+
+	assert.Eventually(t, func() bool {
+		return env.mailContaining(t, created.OrderID) != ""
+	}, 5*time.Second, 50*time.Millisecond, "payment success mail was not sent")
+}
+
+func TestIntegration_QrisPaymentExpires(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "qris_buyer")
+
+	var created models.CreateQrisPaymentResponse
+	status := env.do(t, http.MethodPost, "/api/v1/payments/qris", token, models.CreateQrisPaymentRequest{Items: paymentRequest().Items}, &created)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, strings.HasPrefix(created.QrCodeUrl, env.sim.URL()))
+
+	require.NoError(t, env.sim.Expire(created.OrderID))
+
+	var history models.PaymentHistoryResponse
+	status = env.do(t, http.MethodGet, "/api/v1/payments/history?status=failed", token, nil, &history)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, history.Data, 1)
+	assert.Equal(t, created.OrderID, history.Data[0].ID)
+	assert.Equal(t, "qris", history.Data[0].PaymentType)
+}
+
+func TestIntegration_NotificationSignature(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "signer")
+
+	var created models.CreatePaymentResponse
+	status := env.do(t, http.MethodPost, "/api/v1/payments/create", token, paymentRequest(), &created)
+	require.Equal(t, http.StatusOK, status)
+
+	tests := []struct {
+		name           string
+		signature      string
+		expectedStatus int
+		expectedTx     string
+	}{
+		{
+			name:           "Negative: Forged signature",
+			signature:      utils.MidtransSignature(created.OrderID, "200", "61000.00", "wrong-key"),
+			expectedStatus: http.StatusForbidden,
+			expectedTx:     "pending",
+		},
+		{
+			name:           "Negative: Missing signature",
+			signature:      "",
+			expectedStatus: http.StatusForbidden,
+			expectedTx:     "pending",
+		},
+		{
+			name:           "Positive: Signed with the server key",
+			signature:      utils.MidtransSignature(created.OrderID, "200", "61000.00", testServerKey),
+			expectedStatus: http.StatusOK,
+			expectedTx:     "success",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := env.do(t, http.MethodPost, "/api/v1/payments/notification", "", map[string]interface{}{
+				"order_id":           created.OrderID,
+				"status_code":        "200",
+				"gross_amount":       "61000.00",
+				"transaction_status": "settlement",
+				"payment_type":       "bank_transfer",
+				"signature_key":      tt.signature,
+			}, nil)
+			assert.Equal(t, tt.expectedStatus, status)
+
+			var tx models.Transaction
+			env.do(t, http.MethodGet, "/api/v1/payments/status/"+created.OrderID, token, nil, &tx)
+			assert.Equal(t, tt.expectedTx, tx.Status)
+		})
+	}
+}
+
+func TestIntegration_PaymentRequiresVerifiedEmail(t *testing.T) {
+	env := newTestEnv(t)
+
+	status := env.do(t, http.MethodPost, "/api/v1/auth/register", "", models.RegisterRequest{
+		FullName:    "Unverified",
+		Username:    "unverified",
+		Email:       "unverified@example.com",
+		Password:    "secret123",
+		Address:     "Jl. Sudirman 1",
+		PhoneNumber: "081234567890",
+		City:        "Jakarta",
+		PostalCode:  "10220",
+	}, nil)
+	require.Equal(t, http.StatusCreated, status)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	status = env.do(t, http.MethodPost, "/api/v1/auth/login", "", models.LoginRequest{Username: "unverified", Password: "secret123"}, &login)
+	require.Equal(t, http.StatusOK, status)
+
+	status = env.do(t, http.MethodPost, "/api/v1/payments/create", login.Token, paymentRequest(), nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Empty(t, env.sim.Transactions())
+}
+
+func TestIntegration_UsersBeforeEmailVerificationCanPay(t *testing.T) {
+	env := newTestEnv(t)
+
+	// note : roll back to the initial schema and add a user the way it was
+	// stored before email verification existed
+	migrator, err := storage.NewMigrator(env.app.DB)
+	require.NoError(t, err)
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	_, err = migrator.Down(len(statuses) - 1)
+	require.NoError(t, err)
+	hashedPassword, err := utils.HashPassword("secret123")
+	require.NoError(t, err)
+	require.NoError(t, env.app.DB.Exec(`INSERT INTO users (full_name, username, email, password, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, "Legacy User", "legacy", "legacy@example.com", hashedPassword, time.Now(), time.Now()).Error)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	status := env.do(t, http.MethodPost, "/api/v1/auth/login", "", models.LoginRequest{Username: "legacy", Password: "secret123"}, &login)
+	require.Equal(t, http.StatusOK, status)
+
+	status = env.do(t, http.MethodPost, "/api/v1/payments/create", login.Token, paymentRequest(), nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestIntegration_RequestID(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "Positive: Keeps the incoming ID", incoming: "req-123.abc", keep: true},
+		{name: "Positive: Assigns an ID when missing", incoming: ""},
+		{name: "Negative: Replaces an unsafe ID", incoming: "bad id\" injected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, env.server.URL+"/health", nil)
+			require.NoError(t, err)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			requestID := resp.Header.Get("X-Request-ID")
+			assert.NotEmpty(t, requestID)
+			if tt.keep {
+				assert.Equal(t, tt.incoming, requestID)
+			} else {
+				assert.NotEqual(t, tt.incoming, requestID)
+			}
+		})
+	}
+}
+
+func TestIntegration_Metrics(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "observer")
+
+	var created models.CreatePaymentResponse
+	status := env.do(t, http.MethodPost, "/api/v1/payments/create", token, paymentRequest(), &created)
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, env.sim.Settle(created.OrderID))
+
+	resp, err := http.Get(env.server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, series := range []string{
+		`payments_created_total{outcome="success",type="snap"} 1`,
+		`payment_notifications_total{signature="valid",transaction_status="settlement"} 1`,
+		`midtrans_request_duration_seconds_count{operation="create_transaction",outcome="success"} 1`,
+		`http_requests_total{method="POST",route="/api/v1/payments/create",status="200"} 1`,
+		`go_sql_max_open_connections{db_name="sqlite"} 1`,
+	} {
+		assert.Contains(t, string(body), series)
+	}
+}
+
+func TestIntegration_HealthProbes(t *testing.T) {
+	t.Setenv("READINESS_CHECK_MIDTRANS", "true")
+	env := newTestEnv(t)
+
+	var ready struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	status := env.do(t, http.MethodGet, "/health/ready", "", nil, &ready)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]string{"shutdown": "ok", "database": "ok", "midtrans": "ok"}, ready.Checks)
+
+	env.app.StartDraining()
+
+	status = env.do(t, http.MethodGet, "/health/ready", "", nil, &ready)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", ready.Status)
+	assert.Equal(t, "server is shutting down", ready.Checks["shutdown"])
+
+	status = env.do(t, http.MethodGet, "/health/live", "", nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestIntegration_RateLimit(t *testing.T) {
+	t.Setenv("RATE_LIMIT_LOGIN", "2/m")
+	t.Setenv("RATE_LIMIT_PAYMENTS", "1/h")
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "hasty")
+
+	attempt := 0
+	login := func() *http.Response {
+		raw, err := json.Marshal(models.LoginRequest{Username: "hasty", Password: "wrong-password"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, env.server.URL+"/api/v1/auth/login", bytes.NewReader(raw))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		// a forged header must not give the client a fresh bucket
+		attempt++
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", attempt))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// note : registerVerifiedUser already used the first login of the quota
+	resp := login()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", resp.Header.Get("RateLimit-Policy"))
+
+	resp = login()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	status := env.do(t, http.MethodPost, "/api/v1/payments/create", token, paymentRequest(), nil)
+	require.Equal(t, http.StatusOK, status)
+	status = env.do(t, http.MethodPost, "/api/v1/payments/qris", token, models.CreateQrisPaymentRequest{}, nil)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Len(t, env.sim.Transactions(), 1)
+
+	// other routes keep their own quota
+	status = env.do(t, http.MethodGet, "/api/v1/payments/history", token, nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestIntegration_ErrorEnvelope(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "taken")
+
+	register := func(username, email string) models.RegisterRequest {
+		return models.RegisterRequest{
+			FullName:    "Duplicate",
+			Username:    username,
+			Email:       email,
+			Password:    "secret123",
+			Address:     "Jl. Sudirman 1",
+			PhoneNumber: "081234567890",
+			City:        "Jakarta",
+			PostalCode:  "10220",
+		}
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           interface{}
+		expectedStatus int
+		expectedCode   string
+		expectedFields []apperror.FieldError
+	}{
+		{
+			name:           "Negative: Username taken",
+			method:         http.MethodPost,
+			path:           "/api/v1/auth/register",
+			body:           register("taken", "other@example.com"),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "USERNAME_TAKEN",
+			expectedFields: []apperror.FieldError{{Field: "username", Message: "is already taken"}},
+		},
+		{
+			name:           "Negative: Email taken",
+			method:         http.MethodPost,
+			path:           "/api/v1/auth/register",
+			body:           register("other", "taken@example.com"),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "EMAIL_TAKEN",
+			expectedFields: []apperror.FieldError{{Field: "email", Message: "is already registered"}},
+		},
+		{
+			name:           "Negative: Validation failed",
+			method:         http.MethodPost,
+			path:           "/api/v1/auth/register",
+			body:           models.RegisterRequest{FullName: "Invalid", Username: "invalid", Email: "not-an-email", Password: "short", Address: "Jl. Sudirman 1", PhoneNumber: "081234567890", City: "Jakarta", PostalCode: "10220"},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "VALIDATION_FAILED",
+			expectedFields: []apperror.FieldError{
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "password", Message: "must be at least 6 characters long"},
+			},
+		},
+		{
+			name:           "Negative: Invalid credentials",
+			method:         http.MethodPost,
+			path:           "/api/v1/auth/login",
+			body:           models.LoginRequest{Username: "taken", Password: "wrong-password"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "INVALID_CREDENTIALS",
+		},
+		{
+			name:           "Negative: Transaction not found",
+			method:         http.MethodGet,
+			path:           "/api/v1/payments/status/unknown-order",
+			token:          token,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "TRANSACTION_NOT_FOUND",
+		},
+		{
+			name:           "Negative: Unknown route",
+			method:         http.MethodGet,
+			path:           "/api/v1/unknown",
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "ROUTE_NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp models.ErrorResponse
+			status := env.do(t, tt.method, tt.path, tt.token, tt.body, &resp)
+
+			assert.Equal(t, tt.expectedStatus, status)
+			assert.Equal(t, tt.expectedCode, resp.Code)
+			assert.NotEmpty(t, resp.Error)
+			assert.NotEmpty(t, resp.RequestID)
+			assert.Equal(t, tt.expectedFields, resp.Fields)
+		})
+	}
+}
+
+func TestIntegration_ErrorEnvelopeHidesInternalErrors(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "unlucky")
+
+	sqlDB, err := env.app.DB.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	var resp models.ErrorResponse
+	status := env.do(t, http.MethodGet, "/api/v1/payments/history", token, nil, &resp)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "INTERNAL_ERROR", resp.Code)
+	assert.Equal(t, "internal server error", resp.Error)
+}
+
+func TestIntegration_LoginIPThrottleIgnoresForwardedFor(t *testing.T) {
+	t.Setenv("LOGIN_IP_MAX_FAILURES", "3")
+	t.Setenv("RATE_LIMIT_LOGIN", "off")
+	env := newTestEnv(t)
+
+	for i := 1; i <= 4; i++ {
+		raw, err := json.Marshal(models.LoginRequest{Username: fmt.Sprintf("guess-%d", i), Password: "wrong-password"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, env.server.URL+"/api/v1/auth/login", bytes.NewReader(raw))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		var body models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+
+		if i <= 3 {
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			continue
+		}
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "LOGIN_THROTTLED", body.Code)
+	}
+}
+
+func TestIntegration_PasswordChecksShareLoginLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "3")
+	t.Setenv("LOGIN_DELAY_BASE", "1ns")
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "victim")
+
+	var resp models.ErrorResponse
+	for i := 0; i < 2; i++ {
+		status := env.do(t, http.MethodPost, "/api/v1/profile/password", token, models.ChangePasswordRequest{CurrentPassword: fmt.Sprintf("guess-%d", i), NewPassword: "newsecret123"}, &resp)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "INCORRECT_PASSWORD", resp.Code)
+	}
+	status := env.do(t, http.MethodDelete, "/api/v1/profile", token, models.DeleteAccountRequest{Password: "guess-2"}, &resp)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// note : the account is now locked, even for the right password
+	status = env.do(t, http.MethodPost, "/api/v1/profile/password", token, models.ChangePasswordRequest{CurrentPassword: "secret123", NewPassword: "newsecret123"}, &resp)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "LOGIN_THROTTLED", resp.Code)
+
+	status = env.do(t, http.MethodPost, "/api/v1/auth/login", "", models.LoginRequest{Username: "victim", Password: "secret123"}, &resp)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "LOGIN_THROTTLED", resp.Code)
+}
+
+func TestIntegration_DeleteAccountScrubsNotifications(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "leaving")
+	var user models.User
+	require.NoError(t, env.app.DB.Where("username = ?", "leaving").First(&user).Error)
+
+	require.NoError(t, env.app.DB.Create(&models.NotificationPreference{UserID: user.ID, Language: "id", PaymentSuccess: true}).Error)
+	require.NoError(t, env.app.DB.Create(&models.NotificationLog{UserID: user.ID, TransactionID: "ORDER-LEAVING", Event: "payment_success", Channel: "email", Recipient: user.Email}).Error)
+
+	status := env.do(t, http.MethodDelete, "/api/v1/profile", token, models.DeleteAccountRequest{Password: "secret123"}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var count int64
+	require.NoError(t, env.app.DB.Model(&models.NotificationLog{}).Where("recipient = ?", user.Email).Count(&count).Error)
+	assert.Zero(t, count)
+	require.NoError(t, env.app.DB.Model(&models.NotificationLog{}).Where("user_id = ?", user.ID).Count(&count).Error)
+	assert.EqualValues(t, 1, count)
+	require.NoError(t, env.app.DB.Model(&models.NotificationPreference{}).Where("user_id = ?", user.ID).Count(&count).Error)
+	assert.Zero(t, count)
+	require.NoError(t, env.app.DB.Unscoped().Model(&models.User{}).Where("email = ?", user.Email).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestIntegration_ReportsAggregateUnmaterializedDays(t *testing.T) {
+	env := newTestEnv(t)
+	env.registerVerifiedUser(t, "reporter")
+	var user models.User
+	require.NoError(t, env.app.DB.Where("username = ?", "reporter").First(&user).Error)
+
+	location, err := time.LoadLocation(env.app.Config.ReportTimezone)
+	require.NoError(t, err)
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	day := func(daysAgo int) time.Time {
+		return today.AddDate(0, 0, -daysAgo).Add(12 * time.Hour)
+	}
+
+	// note : older than REPORT_RECOMPUTE_DAYS, so the nightly job never
+	// materializes them
+	for i, tx := range []models.Transaction{
+		{Amount: 50000, Status: "success", PaymentType: "qris", CreatedAt: day(30)},
+		{Amount: 20000, Status: "success", PaymentType: "qris", CreatedAt: day(10)},
+		{Amount: 70000, Status: "pending", PaymentType: "qris", CreatedAt: day(1)},
+	} {
+		tx.ID = fmt.Sprintf("ORDER-REPORT-%d", i)
+		tx.UserID = user.ID
+		require.NoError(t, env.app.DB.Create(&tx).Error)
+	}
+	require.NoError(t, env.app.ReportService.MaterializeDay(context.Background(), day(10)))
+
+	query := &models.ReportQuery{From: today.AddDate(0, 0, -40), To: today}
+	summary, err := env.app.ReportService.GetSummary(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), summary.TransactionCount)
+	assert.Equal(t, int64(70000), summary.GrossAmount)
+
+	report, err := env.app.ReportService.GetDailyReport(context.Background(), query)
+	require.NoError(t, err)
+	var dates []string
+	for _, row := range report.Rows {
+		dates = append(dates, row.Date)
+	}
+	assert.Equal(t, []string{day(30).Format("2006-01-02"), day(10).Format("2006-01-02"), day(1).Format("2006-01-02")}, dates)
+}
+
+func TestIntegration_ReportsBucketDaysInReportTimezone(t *testing.T) {
+	env := newTestEnv(t)
+	env.registerVerifiedUser(t, "night-owl")
+	var user models.User
+	require.NoError(t, env.app.DB.Where("username = ?", "night-owl").First(&user).Error)
+
+	location, err := time.LoadLocation(env.app.Config.ReportTimezone)
+	require.NoError(t, err)
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	lateEvening := today.AddDate(0, 0, -5).Add(23*time.Hour + 30*time.Minute)
+	pastMidnight := today.AddDate(0, 0, -4).Add(30 * time.Minute)
+
+	// note : both are stored in UTC, where they fall on the same day
+	for i, createdAt := range []time.Time{lateEvening, pastMidnight} {
+		tx := models.Transaction{ID: fmt.Sprintf("ORDER-NIGHT-%d", i), UserID: user.ID, Amount: 10000, Status: "success", PaymentType: "qris", CreatedAt: createdAt}
+		require.NoError(t, env.app.DB.Create(&tx).Error)
+	}
+
+	query := &models.ReportQuery{From: today.AddDate(0, 0, -6), To: today}
+	report, err := env.app.ReportService.GetDailyReport(context.Background(), query)
+	require.NoError(t, err)
+	require.Len(t, report.Rows, 2)
+	assert.Equal(t, lateEvening.Format("2006-01-02"), report.Rows[0].Date)
+	assert.Equal(t, pastMidnight.Format("2006-01-02"), report.Rows[1].Date)
+	assert.Equal(t, int64(1), report.Rows[0].TransactionCount)
+	assert.Equal(t, int64(1), report.Rows[1].TransactionCount)
+
+	t.Run("Positive: Materializing again replaces changed rows", func(t *testing.T) {
+		ctx := context.Background()
+		require.NoError(t, env.app.ReportService.MaterializeDay(ctx, lateEvening))
+		require.NoError(t, env.app.ReportService.MaterializeDay(ctx, lateEvening))
+		require.NoError(t, env.app.DB.Model(&models.Transaction{}).Where("id = ?", "ORDER-NIGHT-0").Update("status", "failure").Error)
+		require.NoError(t, env.app.ReportService.MaterializeDay(ctx, lateEvening))
+
+		var stored []models.DailyTransactionSummary
+		require.NoError(t, env.app.DB.Where("report_date = ?", lateEvening.Format("2006-01-02")).Find(&stored).Error)
+		require.Len(t, stored, 1)
+		assert.Equal(t, "failure", stored[0].Status)
+		assert.Equal(t, int64(1), stored[0].TransactionCount)
+	})
+}
+
+func TestIntegration_TransactionExportEscapesFormulas(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "spreadsheet")
+
+	req := paymentRequest()
+	req.Items[0].Name = `=HYPERLINK("http://evil.example","click")`
+	req.Items[1].Name = "+cmd|' /C calc'!A0"
+	status := env.do(t, http.MethodPost, "/api/v1/payments/create", token, req, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	download := func(format string) []byte {
+		httpReq, err := http.NewRequest(http.MethodGet, env.server.URL+"/api/v1/payments/export?format="+format, nil)
+		require.NoError(t, err)
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(httpReq)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return body
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(download("csv"))).ReadAll()
+	require.NoError(t, err)
+	var names []string
+	for _, record := range records[1:] {
+		names = append(names, record[8])
+	}
+	assert.ElementsMatch(t, []string{`'=HYPERLINK("http://evil.example","click")`, "'+cmd|' /C calc'!A0"}, names)
+
+	workbook := download("xlsx")
+	archive, err := zip.NewReader(bytes.NewReader(workbook), int64(len(workbook)))
+	require.NoError(t, err)
+	sheet, err := archive.Open("xl/worksheets/sheet1.xml")
+	require.NoError(t, err)
+	content, err := io.ReadAll(sheet)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "<t>&#39;=HYPERLINK(")
+	assert.NotContains(t, string(content), "<t>=HYPERLINK(")
+}
+
+func TestIntegration_TransactionExportReleasesConnection(t *testing.T) {
+	env := newTestEnv(t)
+	env.registerVerifiedUser(t, "streamer")
+	var user models.User
+	require.NoError(t, env.app.DB.Where("username = ?", "streamer").First(&user).Error)
+
+	createdAt := time.Now().Add(-time.Hour)
+	transactions := make([]models.Transaction, 0, 1203)
+	for i := 0; i < cap(transactions); i++ {
+		tx := models.Transaction{
+			ID:        fmt.Sprintf("ORDER-STREAM-%04d", i),
+			UserID:    user.ID,
+			Amount:    int64(10000 + i%7),
+			Status:    "success",
+			CreatedAt: createdAt.Add(time.Duration(i/3) * time.Second),
+		}
+		if i%2 == 0 {
+			tx.Items = []models.TransactionItem{
+				{ItemID: "PRD-001", Name: "Kopi Susu", Price: 5000, Quantity: 1},
+				{ItemID: "PRD-002", Name: "Roti Bakar", Price: 5000, Quantity: 1},
+			}
+		}
+		transactions = append(transactions, tx)
+	}
+	require.NoError(t, env.app.DB.CreateInBatches(transactions, 200).Error)
+	expectedRows := 0
+	for _, tx := range transactions {
+		expectedRows += max(len(tx.Items), 1)
+	}
+
+	for _, sort := range []string{"", models.HistorySortCreatedAtAsc, models.HistorySortAmountDesc, models.HistorySortAmountAsc} {
+		t.Run("Sort "+sort, func(t *testing.T) {
+			rows := 0
+			seen := make(map[string]bool)
+			err := env.app.TransactionRepo.StreamExportRows(context.Background(), &models.TransactionFilter{UserID: &user.ID, Sort: sort}, func(row *models.TransactionExportRow) error {
+				// note : SQLite has a single connection, this would block until the
+				// timeout if the export still held it
+				if rows == 0 {
+					ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+					defer cancel()
+					if _, err := env.app.TransactionRepo.CountByUserID(ctx, user.ID); err != nil {
+						return err
+					}
+				}
+				rows++
+				seen[row.TransactionID] = true
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Len(t, seen, len(transactions))
+			assert.Equal(t, expectedRows, rows)
+		})
+	}
+}
+
+func TestIntegration_DataExport(t *testing.T) {
+	t.Setenv("EXPORT_SYNC_MAX_TRANSACTIONS", "5000")
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "exporter")
+	var user models.User
+	require.NoError(t, env.app.DB.Where("username = ?", "exporter").First(&user).Error)
+	require.NoError(t, env.app.DB.Model(&user).Update("full_name", "=cmd|' /C calc'!A0").Error)
+
+	// note : more transactions than fit in one page, several of them sharing
+	// a timestamp so paging has to break ties by ID
+	createdAt := time.Now().Add(-time.Hour)
+	transactions := make([]models.Transaction, 0, 1203)
+	for i := 0; i < cap(transactions); i++ {
+		transactions = append(transactions, models.Transaction{
+			ID:        fmt.Sprintf("ORDER-EXPORT-%04d", i),
+			UserID:    user.ID,
+			Amount:    10000,
+			Status:    "success",
+			CreatedAt: createdAt.Add(time.Duration(i/3) * time.Second),
+			Items:     []models.TransactionItem{{ItemID: "PRD-001", Name: "Kopi Susu", Price: 10000, Quantity: 1}},
+		})
+	}
+	transactions[0].Items[0].Name = "=HYPERLINK(\"https://evil.example\")"
+	require.NoError(t, env.app.DB.CreateInBatches(transactions, 200).Error)
+
+	requestExport := func() *http.Response {
+		req, err := http.NewRequest(http.MethodGet, env.server.URL+"/api/v1/profile/export", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := requestExport()
+	archiveBytes, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	archive, err := zip.NewReader(bytes.NewReader(archiveBytes), int64(len(archiveBytes)))
+	require.NoError(t, err)
+	file, err := archive.Open("transactions.csv")
+	require.NoError(t, err)
+	records, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	orderIDs := make(map[string]bool)
+	for _, record := range records[1:] {
+		orderIDs[record[0]] = true
+	}
+	assert.Len(t, orderIDs, len(transactions))
+
+	readCSV := func(name string) [][]string {
+		file, err := archive.Open(name)
+		require.NoError(t, err)
+		defer file.Close()
+		records, err := csv.NewReader(file).ReadAll()
+		require.NoError(t, err)
+		return records
+	}
+	assert.Equal(t, "'=cmd|' /C calc'!A0", readCSV("profile.csv")[1][1])
+	items := readCSV("transaction_items.csv")
+	assert.Len(t, items, len(transactions)+1)
+	assert.Contains(t, items, []string{"ORDER-EXPORT-0000", "PRD-001", "'=HYPERLINK(\"https://evil.example\")", "10000", "1"})
+
+	dataFile, err := archive.Open("data.json")
+	require.NoError(t, err)
+	var data struct {
+		Profile struct {
+			FullName string `json:"full_name"`
+		} `json:"profile"`
+		Transactions []struct {
+			OrderID string `json:"order_id"`
+		} `json:"transactions"`
+		LoginHistory []json.RawMessage `json:"login_history"`
+	}
+	require.NoError(t, json.NewDecoder(dataFile).Decode(&data))
+	dataFile.Close()
+	assert.Equal(t, "=cmd|' /C calc'!A0", data.Profile.FullName)
+	assert.Len(t, data.Transactions, len(transactions))
+	assert.NotEmpty(t, data.LoginHistory)
+
+	t.Run("Negative: Requested again too soon", func(t *testing.T) {
+		resp := requestExport()
+		resp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("Negative: Previous export still pending", func(t *testing.T) {
+		require.NoError(t, env.app.DB.Create(&models.DataExport{ID: "pending-export", UserID: user.ID, Status: models.DataExportStatusPending}).Error)
+		resp := requestExport()
+		resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		require.NoError(t, env.app.DB.Delete(&models.DataExport{}, "id = ?", "pending-export").Error)
+	})
+
+	t.Run("Positive: Expired archives are removed", func(t *testing.T) {
+		var export models.DataExport
+		require.NoError(t, env.app.DB.Where("user_id = ? AND status = ?", user.ID, models.DataExportStatusReady).First(&export).Error)
+		require.FileExists(t, export.FilePath)
+
+		expired := time.Now().Add(-time.Minute)
+		require.NoError(t, env.app.DB.Model(&export).Update("expires_at", expired).Error)
+		require.NoError(t, env.app.DataExportService.RemoveExpired(context.Background()))
+
+		assert.NoFileExists(t, export.FilePath)
+		require.NoError(t, env.app.DB.First(&export, "id = ?", export.ID).Error)
+		assert.Empty(t, export.FilePath)
+	})
+}
+
+// serveSMTP answers a single SMTP session on listener with canned replies and
+// returns the DATA payload it received.
+func serveSMTP(t *testing.T, listener net.Listener) <-chan string {
+	t.Helper()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				body, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- string(body)
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return received
+}
+
+func TestIntegration_DataExportSinglePending(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "impatient")
+	var user models.User
+	require.NoError(t, env.app.DB.Where("username = ?", "impatient").First(&user).Error)
+
+	// note : two requests that both passed the check race to insert, the
+	// database keeps only one of them
+	require.NoError(t, env.app.DB.Create(&models.DataExport{ID: "first", UserID: user.ID, Status: models.DataExportStatusPending}).Error)
+	err := env.app.DB.Create(&models.DataExport{ID: "second", UserID: user.ID, Status: models.DataExportStatusProcessing}).Error
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	// a pending export that outlived EXPORT_TIMEOUT no longer blocks the user
+	require.NoError(t, env.app.DB.Model(&models.DataExport{}).Where("id = ?", "first").Update("created_at", time.Now().Add(-env.app.Config.ExportTimeout-time.Minute)).Error)
+	status := env.do(t, http.MethodGet, "/api/v1/profile/export", token, nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var first models.DataExport
+	require.NoError(t, env.app.DB.First(&first, "id = ?", "first").Error)
+	assert.Equal(t, models.DataExportStatusFailed, first.Status)
+}
+
+func TestIntegration_SMTPMailSender(t *testing.T) {
+	newSender := func(t *testing.T, listener net.Listener) services.MailSender {
+		host, port, err := net.SplitHostPort(listener.Addr().String())
+		require.NoError(t, err)
+		return services.NewSMTPMailSender(&config.Config{SMTPHost: host, SMTPPort: port, MailFrom: "no-reply@localhost", SMTPTimeout: time.Minute})
+	}
+	msg := &services.MailMessage{To: []string{"budi@example.com"}, Subject: "Hello", TextBody: "Hi Budi"}
+
+	t.Run("Positive: Message is delivered", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		received := serveSMTP(t, listener)
+
+		require.NoError(t, newSender(t, listener).Send(context.Background(), msg))
+		assert.Contains(t, <-received, "Subject: Hello")
+	})
+
+	t.Run("Negative: Stalled server is abandoned when the context ends", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		// note : the connection is accepted but the greeting never comes
+		go func() {
+			conn, err := listener.Accept()
+			if err == nil {
+				defer conn.Close()
+				time.Sleep(5 * time.Second)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		assert.Error(t, newSender(t, listener).Send(ctx, msg))
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+}
+
+// currentTOTP computes the code an authenticator app shows for secret.
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestIntegration_TOTPSecretEncryptedAtRest(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.registerVerifiedUser(t, "totpuser")
+
+	storedSecret := func() string {
+		var user models.User
+		require.NoError(t, env.app.DB.Where("username = ?", "totpuser").First(&user).Error)
+		return user.TOTPSecret
+	}
+
+	var enroll models.TwoFactorEnrollResponse
+	status := env.do(t, http.MethodPost, "/api/v1/auth/2fa/enroll", token, nil, &enroll)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, enroll.Secret)
+
+	stored := storedSecret()
+	assert.True(t, strings.HasPrefix(stored, "v1:"), "stored secret %q is not sealed", stored)
+	assert.NotContains(t, stored, enroll.Secret)
+
+	status = env.do(t, http.MethodPost, "/api/v1/auth/2fa/confirm", token, models.TwoFactorCodeRequest{Code: currentTOTP(t, enroll.Secret)}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	t.Run("Positive: Secret stays out of the profile", func(t *testing.T) {
+		var profile map[string]interface{}
+		status := env.do(t, http.MethodGet, "/api/v1/profile", token, nil, &profile)
+		require.Equal(t, http.StatusOK, status)
+		body, err := json.Marshal(profile)
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), enroll.Secret)
+		assert.NotContains(t, string(body), stored)
+	})
+
+	t.Run("Edge: Plaintext secret from before encryption is sealed on login", func(t *testing.T) {
+		require.NoError(t, env.app.DB.Exec("UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE username = ?", enroll.Secret, "totpuser").Error)
+
+		var login models.LoginResponse
+		status := env.do(t, http.MethodPost, "/api/v1/auth/login", "", models.LoginRequest{Username: "totpuser", Password: "secret123"}, &login)
+		require.Equal(t, http.StatusOK, status)
+		require.True(t, login.TwoFactorRequired)
+
+		status = env.do(t, http.MethodPost, "/api/v1/auth/login/2fa", "", models.TwoFactorLoginRequest{ChallengeToken: login.ChallengeToken, Code: currentTOTP(t, enroll.Secret)}, &login)
+		require.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, login.Token)
+
+		resealed := storedSecret()
+		assert.True(t, strings.HasPrefix(resealed, "v1:"), "stored secret %q is not sealed", resealed)
+		assert.NotEqual(t, stored, resealed)
+	})
+}
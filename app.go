@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/app"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/tracing"
+	"github.com/bagussubagja/backend-payment-gateway-go/storage"
+)
+
+func newApp() (*app.App, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := storage.NewDB(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to db: %w", err)
+	}
+	return app.New(cfg, db)
+}
+
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load config: %w", err)
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// waitForWorkers lets the status mails sent in the background go out before
+// a command exits.
+func waitForWorkers(a *app.App) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+	defer cancel()
+	if err := a.Workers.Wait(ctx); err != nil {
+		slog.Error("background work did not finish", "error", err)
+	}
+}
+
+// startTracing installs the tracer provider and returns a function that
+// flushes the pending spans.
+func startTracing(a *app.App) (func(), error) {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    a.Config.TracingExporter,
+		ServiceName: a.Config.TracingServiceName,
+		SampleRatio: a.Config.TracingSampleRatio,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not set up tracing: %w", err)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("could not flush spans", "error", err)
+		}
+	}, nil
+}
+
+func newMigrator(a *app.App) (*storage.Migrator, error) {
+	migrator, err := storage.NewMigrator(a.DB)
+	if err != nil {
+		return nil, fmt.Errorf("could not load migrations: %w", err)
+	}
+	return migrator, nil
+}
@@ -1,26 +1,97 @@
 package config
 
 import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
 	"time"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/ratelimit"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/midtrans/midtrans-go"
 )
 
 type Config struct {
-	ServerPort          string        `envconfig:"PORT" default:"8080"`
-	DBHost              string        `envconfig:"DB_HOST" required:"true"`
-	DBPort              string        `envconfig:"DB_PORT" required:"true"`
-	DBUser              string        `envconfig:"DB_USER" required:"true"`
-	DBPassword          string        `envconfig:"DB_PASSWORD" required:"true"`
-	DBName              string        `envconfig:"DB_NAME" required:"true"`
-	JWTSecretKey        string        `envconfig:"JWT_SECRET_KEY" required:"true"`
-	JWTExpiration       time.Duration `envconfig:"JWT_EXPIRATION_HOURS" default:"24h"`
-	MidtransServerKey   string        `envconfig:"MIDTRANS_SERVER_KEY" required:"true"`
-	MidtransClientKey   string        `envconfig:"MIDTRANS_CLIENT_KEY" required:"true"`
-	MidtransEnvironment midtrans.EnvironmentType
-	rawMidtransEnv      string `envconfig:"MIDTRANS_ENVIRONMENT" default:"sandbox"`
+	ServerPort            string        `envconfig:"PORT" default:"8080"`
+	DBDriver              string        `envconfig:"DB_DRIVER" default:"postgres"`
+	DBHost                string        `envconfig:"DB_HOST"`
+	DBPort                string        `envconfig:"DB_PORT"`
+	DBUser                string        `envconfig:"DB_USER"`
+	DBPassword            string        `envconfig:"DB_PASSWORD"`
+	DBName                string        `envconfig:"DB_NAME"`
+	DBSSLMode             string        `envconfig:"DB_SSLMODE" default:"require"`
+	DBSQLitePath          string        `envconfig:"DB_SQLITE_PATH" default:"payment_gateway.db"`
+	DBAutoMigrate         bool          `envconfig:"DB_AUTO_MIGRATE" default:"true"`
+	DBLogLevel            string        `envconfig:"DB_LOG_LEVEL" default:"warn"`
+	DBQueryTimeout        time.Duration `envconfig:"DB_QUERY_TIMEOUT" default:"5s"`
+	DBConnectTimeout      time.Duration `envconfig:"DB_CONNECT_TIMEOUT" default:"1m"`
+	HTTPReadHeaderTimeout time.Duration `envconfig:"HTTP_READ_HEADER_TIMEOUT" default:"10s"`
+	HTTPIdleTimeout       time.Duration `envconfig:"HTTP_IDLE_TIMEOUT" default:"2m"`
+	ShutdownTimeout       time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+	ShutdownDelay         time.Duration `envconfig:"SHUTDOWN_DELAY" default:"0s"`
+	ReadyCheckMidtrans    bool          `envconfig:"READINESS_CHECK_MIDTRANS" default:"false"`
+	LogLevel              slog.Level    `envconfig:"LOG_LEVEL" default:"info"`
+	LogFormat             string        `envconfig:"LOG_FORMAT" default:"json"`
+	MetricsToken          string        `envconfig:"METRICS_TOKEN"`
+	TracingExporter       string        `envconfig:"TRACING_EXPORTER" default:"none"`
+	TracingServiceName    string        `envconfig:"TRACING_SERVICE_NAME" default:"payment-gateway"`
+	TracingSampleRatio    float64       `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
+	JWTSecretKey          string        `envconfig:"JWT_SECRET_KEY" required:"true"`
+	JWTExpiration         time.Duration `envconfig:"JWT_EXPIRATION_HOURS" default:"24h"`
+	MidtransServerKey     string        `envconfig:"MIDTRANS_SERVER_KEY" required:"true"`
+	MidtransClientKey     string        `envconfig:"MIDTRANS_CLIENT_KEY" required:"true"`
+	MidtransEnvironment   midtrans.EnvironmentType
+	rawMidtransEnv        string        `envconfig:"MIDTRANS_ENVIRONMENT" default:"sandbox"`
+	MidtransBaseURL       string        `envconfig:"MIDTRANS_BASE_URL"`
+	MidtransTimeout       time.Duration `envconfig:"MIDTRANS_TIMEOUT" default:"30s"`
+	AppBaseURL            string        `envconfig:"APP_BASE_URL" default:"http://localhost:3000"`
+	PasswordResetTTL      time.Duration `envconfig:"PASSWORD_RESET_TTL" default:"1h"`
+	EmailVerifyTTL        time.Duration `envconfig:"EMAIL_VERIFICATION_TTL" default:"24h"`
+	EmailVerifyResend     time.Duration `envconfig:"EMAIL_VERIFICATION_RESEND_INTERVAL" default:"1m"`
+	TOTPIssuer            string        `envconfig:"TOTP_ISSUER" default:"Payment Gateway"`
+	TOTPEncryptionKey     string        `envconfig:"TOTP_ENCRYPTION_KEY" required:"true"`
+	TwoFactorChallengeTTL time.Duration `envconfig:"TWO_FACTOR_CHALLENGE_TTL" default:"5m"`
+	LoginMaxAttempts      int           `envconfig:"LOGIN_MAX_ATTEMPTS" default:"5"`
+	LoginDelayBase        time.Duration `envconfig:"LOGIN_DELAY_BASE" default:"1s"`
+	LoginLockoutDuration  time.Duration `envconfig:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+	LoginLockoutMax       time.Duration `envconfig:"LOGIN_LOCKOUT_MAX" default:"24h"`
+	LoginIPMaxFailures    int64         `envconfig:"LOGIN_IP_MAX_FAILURES" default:"20"`
+	LoginIPWindow         time.Duration `envconfig:"LOGIN_IP_WINDOW" default:"15m"`
+	ExportDir             string        `envconfig:"EXPORT_DIR" default:"tmp/exports"`
+	ExportTTL             time.Duration `envconfig:"EXPORT_TTL" default:"24h"`
+	ExportSyncMaxTx       int64         `envconfig:"EXPORT_SYNC_MAX_TRANSACTIONS" default:"500"`
+	ExportTimeout         time.Duration `envconfig:"EXPORT_TIMEOUT" default:"30m"`
+	ExportRequestInterval time.Duration `envconfig:"EXPORT_REQUEST_INTERVAL" default:"1h"`
+	ExportCleanupInterval time.Duration `envconfig:"EXPORT_CLEANUP_INTERVAL" default:"1h"`
+	MerchantName          string        `envconfig:"MERCHANT_NAME" default:"Payment Gateway"`
+	MerchantAddress       string        `envconfig:"MERCHANT_ADDRESS"`
+	MerchantEmail         string        `envconfig:"MERCHANT_EMAIL"`
+	MerchantPhone         string        `envconfig:"MERCHANT_PHONE"`
+	SSEHeartbeatInterval  time.Duration `envconfig:"SSE_HEARTBEAT_INTERVAL" default:"15s"`
+	WSPingInterval        time.Duration `envconfig:"WS_PING_INTERVAL" default:"30s"`
+	WSAllowedOrigins      []string      `envconfig:"WS_ALLOWED_ORIGINS"`
+	ReportTimezone        string        `envconfig:"REPORT_TIMEZONE" default:"Asia/Jakarta"`
+	ReportJobHour         int           `envconfig:"REPORT_JOB_HOUR" default:"1"`
+	ReportRecomputeDays   int           `envconfig:"REPORT_RECOMPUTE_DAYS" default:"3"`
+	MailDriver            string        `envconfig:"MAIL_DRIVER" default:"file"`
+	MailFrom              string        `envconfig:"MAIL_FROM" default:"no-reply@localhost"`
+	MailFileDir           string        `envconfig:"MAIL_FILE_DIR" default:"tmp/mail"`
+	SMTPHost              string        `envconfig:"SMTP_HOST"`
+	SMTPPort              string        `envconfig:"SMTP_PORT" default:"587"`
+	SMTPUsername          string        `envconfig:"SMTP_USERNAME"`
+	SMTPPassword          string        `envconfig:"SMTP_PASSWORD"`
+	SMTPTimeout           time.Duration `envconfig:"SMTP_TIMEOUT" default:"10s"`
+
+	TrustedProxies        []string        `envconfig:"TRUSTED_PROXIES"`
+	RateLimitRegister     ratelimit.Limit `envconfig:"RATE_LIMIT_REGISTER" default:"10/h"`
+	RateLimitLogin        ratelimit.Limit `envconfig:"RATE_LIMIT_LOGIN" default:"20/m"`
+	RateLimitAuth         ratelimit.Limit `envconfig:"RATE_LIMIT_AUTH" default:"10/m"`
+	RateLimitNotification ratelimit.Limit `envconfig:"RATE_LIMIT_NOTIFICATION" default:"600/m"`
+	RateLimitPayments     ratelimit.Limit `envconfig:"RATE_LIMIT_PAYMENTS" default:"10/m"`
+	RateLimitAPI          ratelimit.Limit `envconfig:"RATE_LIMIT_API" default:"300/m"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -40,6 +111,19 @@ func LoadConfig() (*Config, error) {
 	} else {
 		c.MidtransEnvironment = midtrans.Sandbox
 	}
+	if c.MidtransBaseURL != "" {
+		if u, err := url.Parse(c.MidtransBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid MIDTRANS_BASE_URL %q", c.MidtransBaseURL)
+		}
+	}
+	if key, err := hex.DecodeString(c.TOTPEncryptionKey); err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid TOTP_ENCRYPTION_KEY, expected 64 hex characters")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q, expected an IP or CIDR", proxy)
+		}
+	}
 
 	return &c, nil
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+)
+
+func runExportTransactions(args []string) {
+	flags := flag.NewFlagSet("export-transactions", flag.ExitOnError)
+	format := flags.String("format", models.ExportFormatCSV, "csv or xlsx")
+	output := flags.String("output", "", "file to write, stdout when empty")
+	userID := flags.Uint("user-id", 0, "only export transactions of this user")
+	from := flags.String("from", "", "first day to export (YYYY-MM-DD)")
+	to := flags.String("to", "", "last day to export (YYYY-MM-DD)")
+	status := flags.String("status", "", "comma separated statuses to export")
+	flags.Parse(args)
+
+	query := &models.TransactionExportQuery{Format: *format}
+	if query.Format != models.ExportFormatCSV && query.Format != models.ExportFormatXLSX {
+		log.Fatalf("export-transactions: unsupported format %q", query.Format)
+	}
+	if *userID != 0 {
+		id := uint(*userID)
+		query.UserID = &id
+	}
+	if *status != "" {
+		query.Status = strings.Split(*status, ",")
+	}
+	var err error
+	if query.From, err = parseExportDate(*from); err != nil {
+		log.Fatalf("export-transactions: invalid --from: %v", err)
+	}
+	if query.To, err = parseExportDate(*to); err != nil {
+		log.Fatalf("export-transactions: invalid --to: %v", err)
+	}
+
+	a, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("could not create %s: %v", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	buffered := bufio.NewWriter(w)
+
+	if err := a.TransactionExportService.Export(context.Background(), buffered, query); err != nil {
+		log.Fatalf("export-transactions: %v", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		log.Fatalf("export-transactions: %v", err)
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", *output)
+	}
+}
+
+func parseExportDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
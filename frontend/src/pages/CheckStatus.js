@@ -1,5 +1,5 @@
-import React, { useState } from 'react';
-import { paymentAPI } from '../services/api';
+import React, { useEffect, useState } from 'react';
+import { paymentAPI, subscribePaymentStatus } from '../services/api';
 import { useTheme } from '../components/ThemeContext';
 
 const CheckStatus = () => {
@@ -9,6 +9,17 @@ const CheckStatus = () => {
   const [error, setError] = useState('');
   const { colors } = useTheme();
 
+  const transactionID = transaction?.ID || transaction?.id;
+
+  useEffect(() => {
+    if (!transactionID) {
+      return undefined;
+    }
+    return subscribePaymentStatus(transactionID, (event) => {
+      setTransaction((current) => current && { ...current, Status: event.status, status: event.status });
+    });
+  }, [transactionID]);
+
   const handleSubmit = async (e) => {
     e.preventDefault();
     setLoading(true);
@@ -27,6 +38,7 @@ const CheckStatus = () => {
 
   const getStatusColor = (status) => {
     switch (status?.toLowerCase()) {
+      case 'success':
       case 'settlement':
       case 'capture':
         return colors.success;
@@ -35,6 +47,7 @@ const CheckStatus = () => {
       case 'cancel':
       case 'expire':
       case 'failure':
+      case 'failed':
         return colors.danger;
       default:
         return colors.textSecondary;
@@ -11,7 +11,7 @@ const History = () => {
       try {
         const response = await paymentAPI.getHistory();
         console.log('History response:', response.data);
-        setTransactions(Array.isArray(response.data) ? response.data : []);
+        setTransactions(Array.isArray(response.data?.data) ? response.data.data : []);
       } catch (err) {
         console.error('History error:', err);
         setError(err.response?.data?.error || err.response?.data?.message || 'Failed to fetch history');
@@ -1,5 +1,5 @@
-import React, { useState } from 'react';
-import { paymentAPI } from '../services/api';
+import React, { useEffect, useState } from 'react';
+import { paymentAPI, subscribePaymentStatus } from '../services/api';
 
 const Payment = () => {
   const [items, setItems] = useState([{ id: '', name: '', price: '', quantity: 1 }]);
@@ -16,6 +16,17 @@ const Payment = () => {
   const [result, setResult] = useState(null);
   const [error, setError] = useState('');
   const [paymentType, setPaymentType] = useState('regular');
+  const [liveStatus, setLiveStatus] = useState('');
+
+  const orderID = result?.order_id;
+
+  useEffect(() => {
+    if (!orderID) {
+      return undefined;
+    }
+    setLiveStatus('pending');
+    return subscribePaymentStatus(orderID, (event) => setLiveStatus(event.status));
+  }, [orderID]);
 
   const addItem = () => {
     setItems([...items, { id: '', name: '', price: '', quantity: 1 }]);
@@ -83,6 +94,7 @@ const Payment = () => {
         <div style={{ padding: '1rem', backgroundColor: '#d4edda', color: '#155724', borderRadius: '4px', marginBottom: '1rem' }}>
           <h3>Payment Created Successfully!</h3>
           <p><strong>Order ID:</strong> {result.order_id}</p>
+          {liveStatus && <p><strong>Status:</strong> {liveStatus}</p>}
           {result.redirect_url && (
             <p><strong>Payment URL:</strong> <a href={result.redirect_url} target="_blank" rel="noopener noreferrer">Pay Now</a></p>
           )}
@@ -33,4 +33,47 @@ export const paymentAPI = {
   getHistory: () => api.get('/payments/history'),
 };
 
+// EventSource cannot send the Authorization header, so the SSE stream is read
+// with fetch. Returns a function that closes the stream.
+export const subscribePaymentStatus = (orderID, onStatus) => {
+  const controller = new AbortController();
+  const token = localStorage.getItem('token');
+
+  const read = async () => {
+    const response = await fetch(`${API_BASE_URL}/payments/${orderID}/events`, {
+      headers: { Authorization: `Bearer ${token}` },
+      signal: controller.signal,
+    });
+    if (!response.ok || !response.body) {
+      return;
+    }
+
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += decoder.decode(value, { stream: true });
+      const messages = buffer.split('\n\n');
+      buffer = messages.pop();
+      messages.forEach((message) => {
+        const data = message
+          .split('\n')
+          .filter((line) => line.startsWith('data:'))
+          .map((line) => line.slice(5))
+          .join('\n');
+        if (data) {
+          onStatus(JSON.parse(data));
+        }
+      });
+    }
+  };
+
+  read().catch(() => {});
+  return () => controller.abort();
+};
+
 export default api;
\ No newline at end of file
@@ -0,0 +1,147 @@
+// Package app wires the repositories and services together, so the server,
+// the ops commands and the integration tests share one setup.
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/api/handler"
+	"github.com/bagussubagja/backend-payment-gateway-go/api/routes"
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/background"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/metrics"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/ratelimit"
+	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+type App struct {
+	Config *config.Config
+	DB     *gorm.DB
+
+	UserRepo        repository.UserRepository
+	TransactionRepo repository.TransactionRepository
+
+	AuthService              services.AuthService
+	UserService              services.UserService
+	PaymentService           services.PaymentService
+	DataExportService        services.DataExportService
+	TransactionExportService services.TransactionExportService
+	ReceiptService           services.ReceiptService
+	ReportService            services.ReportService
+	NotificationService      services.NotificationService
+	MidtransService          services.MidtransService
+	EventHub                 services.EventHub
+	Metrics                  *metrics.Metrics
+	Workers                  *background.Group
+	RateLimitStore           ratelimit.Store
+
+	draining atomic.Bool
+}
+
+func New(cfg *config.Config, db *gorm.DB) (*App, error) {
+	userRepo := repository.NewUserRepository(db)
+	transactionRepo := repository.NewTransactionRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	dataExportRepo := repository.NewDataExportRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+
+	appMetrics := metrics.New()
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("could not get db connection pool: %w", err)
+	}
+	if err := appMetrics.RegisterDB(sqlDB, db.Dialector.Name()); err != nil {
+		return nil, fmt.Errorf("could not register db metrics: %w", err)
+	}
+
+	mailSender, err := services.NewMailSender(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure mail sender: %w", err)
+	}
+
+	totpKey, err := hex.DecodeString(cfg.TOTPEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode totp encryption key: %w", err)
+	}
+	totpBox, err := utils.NewSecretBox(totpKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure totp encryption: %w", err)
+	}
+
+	midtransService := services.NewInstrumentedMidtransService(services.NewMidtransService(cfg), appMetrics)
+	notificationService, err := services.NewNotificationService(notificationRepo, mailSender, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not load notification templates: %w", err)
+	}
+	eventHub := services.NewEventHub()
+	workers := &background.Group{}
+	reportService, err := services.NewReportService(reportRepo, workers, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure report service: %w", err)
+	}
+
+	return &App{
+		Config:                   cfg,
+		DB:                       db,
+		UserRepo:                 userRepo,
+		TransactionRepo:          transactionRepo,
+		AuthService:              services.NewAuthService(userRepo, userTokenRepo, recoveryCodeRepo, loginAttemptRepo, mailSender, totpBox, cfg),
+		UserService:              services.NewUserService(userRepo, loginAttemptRepo, cfg),
+		PaymentService:           services.NewInstrumentedPaymentService(services.NewTracedPaymentService(services.NewPaymentService(transactionRepo, midtransService, notificationService, eventHub, workers, cfg)), appMetrics),
+		DataExportService:        services.NewDataExportService(dataExportRepo, userRepo, transactionRepo, loginAttemptRepo, workers, cfg),
+		TransactionExportService: services.NewTransactionExportService(transactionRepo),
+		ReceiptService:           services.NewReceiptService(transactionRepo, cfg),
+		ReportService:            reportService,
+		NotificationService:      notificationService,
+		MidtransService:          midtransService,
+		EventHub:                 eventHub,
+		Metrics:                  appMetrics,
+		Workers:                  workers,
+		RateLimitStore:           ratelimit.NewMemoryStore(),
+	}, nil
+}
+
+func (a *App) Router() *gin.Engine {
+	return routes.SetupRouter(a.AuthService, a.UserService, a.PaymentService, a.DataExportService, a.TransactionExportService, a.ReportService, a.ReceiptService, a.NotificationService, a.EventHub, a.Metrics, a.RateLimitStore, a.readyChecks(), a.Config)
+}
+
+// StartDraining fails the readiness check from now on, so load balancers stop
+// sending requests before the server shuts down.
+func (a *App) StartDraining() {
+	a.draining.Store(true)
+}
+
+func (a *App) readyChecks() []handler.HealthCheck {
+	checks := []handler.HealthCheck{
+		{Name: "shutdown", Check: func(ctx context.Context) error {
+			if a.draining.Load() {
+				return errShuttingDown
+			}
+			return nil
+		}},
+		{Name: "database", Check: func(ctx context.Context) error {
+			sqlDB, err := a.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}},
+	}
+	if a.Config.ReadyCheckMidtrans {
+		checks = append(checks, handler.HealthCheck{Name: "midtrans", Check: a.MidtransService.Ping})
+	}
+	return checks
+}
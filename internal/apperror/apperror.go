@@ -0,0 +1,105 @@
+// Package apperror defines the errors services return for expected failures.
+// Each one has a kind, which decides the HTTP status, a machine-readable code
+// and a message that is safe to show to clients. Any other error is treated as
+// an internal error and its text never leaves the server.
+package apperror
+
+import "time"
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindGone
+	KindTooManyRequests
+	KindUnavailable
+)
+
+// FieldError points at one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// RetryAfter tells throttled clients when to try again.
+	RetryAfter time.Duration
+	// Err is the underlying cause. It is logged but not sent to clients.
+	Err error
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func Validation(code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message, Fields: fields}
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message, Fields: fields}
+}
+
+func Gone(code, message string) *Error {
+	return New(KindGone, code, message)
+}
+
+func TooManyRequests(code, message string) *Error {
+	return New(KindTooManyRequests, code, message)
+}
+
+func Unavailable(code, message string) *Error {
+	return New(KindUnavailable, code, message)
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports errors with the same code as equal, so copies made by Wrap and
+// WithRetryAfter still match their sentinel with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	throttled := *e
+	throttled.RetryAfter = d
+	return &throttled
+}
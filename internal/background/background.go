@@ -0,0 +1,38 @@
+// Package background tracks the goroutines that outlive a request, such as
+// status emails and large data exports, so shutdown can wait for them.
+package background
+
+import (
+	"context"
+	"sync"
+)
+
+type Group struct {
+	wg sync.WaitGroup
+}
+
+// Go runs fn in a new goroutine that Wait waits for.
+func (g *Group) Go(fn func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every goroutine started with Go has returned, or returns
+// ctx's error once ctx is done.
+func (g *Group) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
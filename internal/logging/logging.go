@@ -0,0 +1,104 @@
+// Package logging sets up the structured slog logger used across the app and
+// carries request scoped loggers through a context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// note : matched against the lowercased attribute key, so "Email" and
+// "customer_email" are both covered by "email"
+var sensitiveKeys = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"signature",
+	"email",
+	"phone",
+	"address",
+	"full_name",
+	"recipient",
+	"otp",
+	"recovery_code",
+}
+
+type ctxKey struct{}
+
+// New returns a logger writing to w in the given format. Attributes with a
+// sensitive key are redacted before they are written.
+func New(w io.Writer, level slog.Level, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	switch format {
+	case FormatJSON, "":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unsupported LOG_FORMAT %q, expected %s or %s", format, FormatJSON, FormatText)
+	}
+}
+
+// Setup installs the logger as the slog default, which also routes the
+// standard log package through it.
+func Setup(level slog.Level, format string) error {
+	logger, err := New(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// IsSensitive reports whether values logged under key are redacted.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the request scoped logger of ctx, or the default logger
+// outside a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger also carries args, e.g. the user or
+// order a request works on.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
@@ -0,0 +1,133 @@
+// Package metrics holds the Prometheus collectors of the app. Each Metrics has
+// its own registry, so several apps can run in one process, e.g. in tests.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	// OutcomeRejected counts requests refused before reaching Midtrans, e.g.
+	// for an unverified email address.
+	OutcomeRejected = "rejected"
+)
+
+// note : notification statuses come from the request body, so anything not
+// sent by Midtrans is folded into "other" to bound the label values
+var notificationStatuses = map[string]bool{
+	"capture":        true,
+	"settlement":     true,
+	"pending":        true,
+	"deny":           true,
+	"cancel":         true,
+	"expire":         true,
+	"failure":        true,
+	"refund":         true,
+	"partial_refund": true,
+	"authorize":      true,
+}
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	paymentsCreated  *prometheus.CounterVec
+	notifications    *prometheus.CounterVec
+	midtransDuration *prometheus.HistogramVec
+	midtransErrors   *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		paymentsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "payments_created_total",
+			Help: "Payment creation attempts by payment type and outcome.",
+		}, []string{"type", "outcome"}),
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "payment_notifications_total",
+			Help: "Midtrans notifications by transaction status and signature validity.",
+		}, []string{"transaction_status", "signature"}),
+		midtransDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "midtrans_request_duration_seconds",
+			Help:    "Latency of Midtrans API calls by operation and outcome.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"operation", "outcome"}),
+		midtransErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "midtrans_request_errors_total",
+			Help: "Failed Midtrans API calls by operation.",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.paymentsCreated,
+		m.notifications,
+		m.midtransDuration,
+		m.midtransErrors,
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool stats of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObservePaymentCreated(paymentType, outcome string) {
+	m.paymentsCreated.WithLabelValues(paymentType, outcome).Inc()
+}
+
+func (m *Metrics) ObserveNotification(transactionStatus string, validSignature bool) {
+	if !notificationStatuses[transactionStatus] {
+		transactionStatus = "other"
+	}
+	signature := "valid"
+	if !validSignature {
+		signature = "invalid"
+	}
+	m.notifications.WithLabelValues(transactionStatus, signature).Inc()
+}
+
+func (m *Metrics) ObserveMidtransCall(operation string, duration time.Duration, failed bool) {
+	outcome := OutcomeSuccess
+	if failed {
+		outcome = OutcomeError
+		m.midtransErrors.WithLabelValues(operation).Inc()
+	}
+	m.midtransDuration.WithLabelValues(operation, outcome).Observe(duration.Seconds())
+}
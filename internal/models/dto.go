@@ -1,5 +1,21 @@
 package models
 
+import (
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+)
+
+// ErrorResponse is the body of every error response. Error is meant for
+// people, clients should branch on Code.
+type ErrorResponse struct {
+	Error      string                `json:"error"`
+	Code       string                `json:"code"`
+	Fields     []apperror.FieldError `json:"fields,omitempty"`
+	RetryAfter int                   `json:"retry_after,omitempty"`
+	RequestID  string                `json:"request_id,omitempty"`
+}
+
 type RegisterRequest struct {
 	FullName    string `json:"full_name" binding:"required"`
 	Username    string `json:"username" binding:"required"`
@@ -12,14 +28,35 @@ type RegisterRequest struct {
 }
 
 type UserResponse struct {
-	ID          uint   `json:"id"`
-	FullName    string `json:"full_name"`
-	Username    string `json:"username"`
-	Email       string `json:"email"`
-	Address     string `json:"address"`
-	PhoneNumber string `json:"phone_number"`
-	City        string `json:"city"`
-	PostalCode  string `json:"postal_code"`
+	ID            uint   `json:"id"`
+	FullName      string `json:"full_name"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	Address       string `json:"address"`
+	PhoneNumber   string `json:"phone_number"`
+	City          string `json:"city"`
+	PostalCode    string `json:"postal_code"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+type UpdateProfileRequest struct {
+	Address     *string `json:"address" binding:"omitempty,min=1,max=255"`
+	PhoneNumber *string `json:"phone_number" binding:"omitempty,min=8,max=20"`
+	City        *string `json:"city" binding:"omitempty,min=1,max=100"`
+	PostalCode  *string `json:"postal_code" binding:"omitempty,numeric,min=4,max=10"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangePasswordResponse struct {
+	Token string `json:"token"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
 }
 
 type LoginRequest struct {
@@ -28,8 +65,41 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token             string        `json:"token,omitempty"`
+	User              *UserResponse `json:"user,omitempty"`
+	TwoFactorRequired bool          `json:"two_factor_required,omitempty"`
+	ChallengeToken    string        `json:"challenge_token,omitempty"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
 }
 
 type ItemDetailRequest struct {
@@ -82,3 +152,218 @@ type CreateQrisPaymentResponse struct {
 	QrCodeUrl  string `json:"qr_code_url"`
 	ExpiryTime string `json:"expiry_time"`
 }
+
+type DataExportResponse struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+const (
+	HistorySortCreatedAtDesc = "created_at_desc"
+	HistorySortCreatedAtAsc  = "created_at_asc"
+	HistorySortAmountDesc    = "amount_desc"
+	HistorySortAmountAsc     = "amount_asc"
+)
+
+type PaymentHistoryQuery struct {
+	Cursor      string    `form:"cursor"`
+	Limit       int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	Status      []string  `form:"status"`
+	From        time.Time `form:"from" time_format:"2006-01-02"`
+	To          time.Time `form:"to" time_format:"2006-01-02"`
+	MinAmount   *int64    `form:"min_amount" binding:"omitempty,min=0"`
+	MaxAmount   *int64    `form:"max_amount" binding:"omitempty,min=0"`
+	PaymentType string    `form:"payment_type"`
+	Sort        string    `form:"sort" binding:"omitempty,oneof=created_at_desc created_at_asc amount_desc amount_asc"`
+}
+
+// TransactionFilter is the repository level form of PaymentHistoryQuery with
+// the cursor already decoded. A nil UserID matches every user.
+type TransactionFilter struct {
+	UserID      *uint
+	Statuses    []string
+	From        *time.Time
+	To          *time.Time
+	MinAmount   *int64
+	MaxAmount   *int64
+	PaymentType string
+	Sort        string
+	After       *TransactionCursor
+	Limit       int
+}
+
+type TransactionCursor struct {
+	CreatedAt time.Time `json:"c"`
+	Amount    int64     `json:"a"`
+	ID        string    `json:"i"`
+}
+
+type TransactionItemResponse struct {
+	ItemID   string `json:"item_id"`
+	Name     string `json:"name"`
+	Price    int64  `json:"price"`
+	Quantity int32  `json:"quantity"`
+}
+
+type TransactionResponse struct {
+	ID          string                    `json:"id"`
+	Amount      int64                     `json:"amount"`
+	Status      string                    `json:"status"`
+	PaymentType string                    `json:"payment_type"`
+	PaymentURL  string                    `json:"payment_url"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+	Items       []TransactionItemResponse `json:"items"`
+}
+
+type PaymentHistoryResponse struct {
+	Data       []TransactionResponse `json:"data"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	TotalCount int64                 `json:"total_count"`
+	Limit      int                   `json:"limit"`
+}
+
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+type TransactionExportQuery struct {
+	PaymentHistoryQuery
+	Format string `form:"format" binding:"omitempty,oneof=csv xlsx"`
+	// UserID narrows admin exports to one user; it is ignored for regular users.
+	UserID *uint `form:"user_id"`
+}
+
+// TransactionExportRow is one transaction item joined with its transaction.
+// Item columns are nil for transactions without items.
+type TransactionExportRow struct {
+	TransactionID string
+	UserID        uint
+	Amount        int64
+	Status        string
+	PaymentType   string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	ItemID        *string
+	ItemName      *string
+	ItemPrice     *int64
+	ItemQuantity  *int32
+}
+
+type ReportQuery struct {
+	From time.Time `form:"from" binding:"required" time_format:"2006-01-02"`
+	To   time.Time `form:"to" binding:"required" time_format:"2006-01-02"`
+}
+
+type RevenueFigures struct {
+	TransactionCount int64   `json:"transaction_count"`
+	SuccessCount     int64   `json:"success_count"`
+	GrossAmount      int64   `json:"gross_amount"`
+	RefundedAmount   int64   `json:"refunded_amount"`
+	NetAmount        int64   `json:"net_amount"`
+	SuccessRate      float64 `json:"success_rate"`
+}
+
+type DailyReportRow struct {
+	Date        string           `json:"date"`
+	PaymentType string           `json:"payment_type"`
+	ByStatus    map[string]int64 `json:"by_status"`
+	RevenueFigures
+}
+
+type DailyReportResponse struct {
+	From string           `json:"from"`
+	To   string           `json:"to"`
+	Rows []DailyReportRow `json:"rows"`
+}
+
+type PaymentTypeSummary struct {
+	PaymentType string `json:"payment_type"`
+	RevenueFigures
+}
+
+type ReportSummaryResponse struct {
+	From          string               `json:"from"`
+	To            string               `json:"to"`
+	ByPaymentType []PaymentTypeSummary `json:"by_payment_type"`
+	RevenueFigures
+}
+
+type NotificationPreferencesRequest struct {
+	Language        *string `json:"language" binding:"omitempty,oneof=id en"`
+	PaymentSuccess  *bool   `json:"payment_success"`
+	PaymentFailed   *bool   `json:"payment_failed"`
+	PaymentRefunded *bool   `json:"payment_refunded"`
+}
+
+type NotificationPreferencesResponse struct {
+	Language        string `json:"language"`
+	PaymentSuccess  bool   `json:"payment_success"`
+	PaymentFailed   bool   `json:"payment_failed"`
+	PaymentRefunded bool   `json:"payment_refunded"`
+}
+
+const (
+	PaymentEventCreated = "transaction.created"
+	PaymentEventUpdated = "transaction.updated"
+)
+
+// PaymentEvent is pushed to live subscribers whenever a transaction is
+// created or its status changes.
+type PaymentEvent struct {
+	Type        string    `json:"type"`
+	OrderID     string    `json:"order_id"`
+	UserID      uint      `json:"user_id"`
+	Amount      int64     `json:"amount"`
+	Status      string    `json:"status"`
+	PaymentType string    `json:"payment_type"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewPaymentEvent describes the current state of a transaction.
+func NewPaymentEvent(eventType string, tx *Transaction) PaymentEvent {
+	return PaymentEvent{
+		Type:        eventType,
+		OrderID:     tx.ID,
+		UserID:      tx.UserID,
+		Amount:      tx.Amount,
+		Status:      tx.Status,
+		PaymentType: tx.PaymentType,
+		UpdatedAt:   tx.UpdatedAt,
+	}
+}
+
+const (
+	PaymentFeedActionSubscribe   = "subscribe"
+	PaymentFeedActionUnsubscribe = "unsubscribe"
+)
+
+// PaymentFeedRequest is sent by WebSocket feed clients to narrow or widen the
+// set of orders they receive events for.
+type PaymentFeedRequest struct {
+	Action   string   `json:"action"`
+	OrderIDs []string `json:"order_ids"`
+}
+
+type PaymentFeedReply struct {
+	Type     string   `json:"type"`
+	All      bool     `json:"all"`
+	OrderIDs []string `json:"order_ids"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type ReconcileFailure struct {
+	OrderID string `json:"order_id"`
+	Error   string `json:"error"`
+}
+
+type ReconcileResult struct {
+	Checked int                `json:"checked"`
+	Updated int                `json:"updated"`
+	Failed  []ReconcileFailure `json:"failed"`
+}
@@ -2,20 +2,79 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type User struct {
-	ID          uint   `gorm:"primaryKey"`
-	FullName    string `gorm:"not null"`
-	Username    string `gorm:"unique;not null"`
-	Email       string `gorm:"unique;not null"`
-	Password    string `gorm:"not null"`
-	Address     string
-	PhoneNumber string
-	City        string
-	PostalCode  string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID                  uint   `gorm:"primaryKey"`
+	FullName            string `gorm:"not null"`
+	Username            string `gorm:"unique;not null"`
+	Email               string `gorm:"unique;not null"`
+	Password            string `gorm:"not null" json:"-"`
+	Address             string
+	PhoneNumber         string
+	City                string
+	PostalCode          string
+	TokenVersion        uint `gorm:"not null;default:0" json:"-"`
+	EmailVerifiedAt     *time.Time
+	TwoFactorEnabled    bool       `gorm:"not null;default:false"`
+	TOTPSecret          string     `json:"-"`
+	TOTPLastStep        int64      `gorm:"not null;default:0" json:"-"`
+	Role                string     `gorm:"not null;default:user"`
+	FailedLoginAttempts int        `gorm:"not null;default:0" json:"-"`
+	LastFailedLoginAt   *time.Time `json:"-"`
+	LockedUntil         *time.Time `json:"-"`
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	UserTokenPurposePasswordReset     = "password_reset"
+	UserTokenPurposeEmailVerification = "email_verification"
+)
+
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+const (
+	LoginAttemptReasonSuccess            = "success"
+	LoginAttemptReasonInvalidCredentials = "invalid_credentials"
+	LoginAttemptReasonUnknownUser        = "unknown_user"
+	LoginAttemptReasonLocked             = "locked"
+	LoginAttemptReasonIPThrottled        = "ip_throttled"
+	LoginAttemptReasonInvalidTwoFactor   = "invalid_two_factor"
+)
+
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	Username  string    `gorm:"not null;index"`
+	UserID    *uint     `gorm:"index"`
+	IPAddress string    `gorm:"not null;index"`
+	Success   bool      `gorm:"not null"`
+	Reason    string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"index"`
 }
 
 type TransactionItem struct {
@@ -29,13 +88,87 @@ type TransactionItem struct {
 
 type Transaction struct {
 	ID                    string `gorm:"primaryKey"`
-	UserID                uint   `gorm:"not null"`
+	UserID                uint   `gorm:"not null;index"`
 	Amount                int64  `gorm:"not null"`
-	Status                string `gorm:"not null"`
+	Status                string `gorm:"not null;index"`
+	PaymentType           string `gorm:"index"`
 	MidtransTransactionID string
 	PaymentURL            string
-	CreatedAt             time.Time
+	SettledAt             *time.Time
+	CreatedAt             time.Time `gorm:"index"`
 	UpdatedAt             time.Time
 	User                  User              `gorm:"foreignKey:UserID"`
 	Items                 []TransactionItem `gorm:"foreignKey:TransactionID"`
 }
+
+type TransactionStatusHistory struct {
+	ID            uint   `gorm:"primaryKey"`
+	TransactionID string `gorm:"not null;index"`
+	Status        string `gorm:"not null"`
+	Source        string `gorm:"not null"`
+	CreatedAt     time.Time
+}
+
+const (
+	DataExportStatusPending    = "pending"
+	DataExportStatusProcessing = "processing"
+	DataExportStatusReady      = "ready"
+	DataExportStatusFailed     = "failed"
+)
+
+type DataExport struct {
+	ID          string `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;index"`
+	Status      string `gorm:"not null"`
+	FilePath    string
+	Error       string
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time
+}
+
+// DailyTransactionSummary is the materialized per-day aggregate used by the
+// admin reports. ReportDate is a YYYY-MM-DD date in the report timezone.
+type DailyTransactionSummary struct {
+	ID               uint   `gorm:"primaryKey"`
+	ReportDate       string `gorm:"size:10;not null;uniqueIndex:idx_daily_summary_key"`
+	PaymentType      string `gorm:"not null;uniqueIndex:idx_daily_summary_key"`
+	Status           string `gorm:"not null;uniqueIndex:idx_daily_summary_key"`
+	TransactionCount int64  `gorm:"not null"`
+	TotalAmount      int64  `gorm:"not null"`
+	UpdatedAt        time.Time
+}
+
+const (
+	NotificationEventPaymentSuccess  = "payment_success"
+	NotificationEventPaymentFailed   = "payment_failed"
+	NotificationEventPaymentRefunded = "payment_refunded"
+)
+
+const (
+	NotificationLanguageIndonesian = "id"
+	NotificationLanguageEnglish    = "en"
+)
+
+// NotificationPreference holds the email settings of a user. Users without a
+// row get every notification in Indonesian.
+type NotificationPreference struct {
+	UserID          uint   `gorm:"primaryKey;autoIncrement:false"`
+	Language        string `gorm:"size:5;not null"`
+	PaymentSuccess  bool   `gorm:"not null"`
+	PaymentFailed   bool   `gorm:"not null"`
+	PaymentRefunded bool   `gorm:"not null"`
+	UpdatedAt       time.Time
+}
+
+// NotificationLog records every notification that was sent. The unique key
+// keeps a notification from going out twice when Midtrans re-sends a webhook.
+type NotificationLog struct {
+	ID            uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"not null;index"`
+	TransactionID string `gorm:"not null;uniqueIndex:idx_notification_log_key"`
+	Event         string `gorm:"not null;uniqueIndex:idx_notification_log_key"`
+	Channel       string `gorm:"not null;uniqueIndex:idx_notification_log_key"`
+	Recipient     string `gorm:"not null"`
+	CreatedAt     time.Time
+}
@@ -0,0 +1,143 @@
+// Package ratelimit implements token bucket rate limiting. Buckets live in a
+// Store, so several server instances can share them by plugging in a store
+// backed by e.g. Redis instead of the in-memory one.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period. The bucket holds up to Requests tokens
+// and refills evenly over the period, so a client can burst through its
+// whole quota and then continues at the average rate.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Decode parses limits such as "10/m", "100/h" or "5/30s", "off" disables the
+// limit. It lets envconfig read Limit from the environment.
+func (l *Limit) Decode(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "off" || value == "0" {
+		*l = Limit{}
+		return nil
+	}
+
+	requests, period, ok := strings.Cut(value, "/")
+	n, err := strconv.Atoi(requests)
+	if !ok || err != nil || n <= 0 {
+		return fmt.Errorf("invalid rate limit %q, expected requests/period such as 10/m", value)
+	}
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid rate limit %q, expected requests/period such as 10/m", value)
+	}
+	if d < time.Duration(n) {
+		return fmt.Errorf("invalid rate limit %q, the period must be at least one nanosecond per request", value)
+	}
+
+	*l = Limit{Requests: n, Period: d}
+	return nil
+}
+
+// Enabled reports whether the limit applies. A period shorter than one
+// nanosecond per request would make the refill interval zero, so such a
+// limit is treated as off; Decode rejects it.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period >= time.Duration(l.Requests)
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// interval is the time it takes to refill one token.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Result describes the bucket after a Take.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when
+	// the request was allowed.
+	RetryAfter time.Duration
+}
+
+type Store interface {
+	// Take removes one token from the bucket of key, creating a full bucket
+	// for unknown keys.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// sweepInterval is how often the memory store drops buckets that have
+// refilled completely, since those behave like new ones.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the buckets of one server instance. A bucket is stored as
+// the time it is full again, each token taken pushes that time back by one
+// refill interval.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]time.Time
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	interval := limit.interval()
+	full := s.buckets[key]
+	if full.Before(now) {
+		full = now
+	}
+
+	result := Result{Limit: limit.Requests}
+	if next := full.Add(interval); next.Sub(now) <= limit.Period {
+		full = next
+		s.buckets[key] = full
+		result.Allowed = true
+	} else {
+		result.RetryAfter = next.Sub(now) - limit.Period
+	}
+	result.Reset = full.Sub(now)
+	result.Remaining = int((limit.Period - result.Reset) / interval)
+	return result, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, full := range s.buckets {
+		if !now.Before(full) {
+			delete(s.buckets, key)
+		}
+	}
+}
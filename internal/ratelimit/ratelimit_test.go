@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimit_Decode(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    Limit
+		expectError bool
+	}{
+		{
+			name:     "Positive: Per minute",
+			value:    "10/m",
+			expected: Limit{Requests: 10, Period: time.Minute},
+		},
+		{
+			name:     "Positive: Period with a count",
+			value:    "5/30s",
+			expected: Limit{Requests: 5, Period: 30 * time.Second},
+		},
+		{
+			name:     "Positive: Off",
+			value:    "off",
+			expected: Limit{},
+		},
+		{
+			name:     "Positive: Zero turns the limit off",
+			value:    "0",
+			expected: Limit{},
+		},
+		{
+			name:     "Edge: One request per nanosecond",
+			value:    "1000/1us",
+			expected: Limit{Requests: 1000, Period: time.Microsecond},
+		},
+		{
+			name:     "Edge: Surrounding whitespace",
+			value:    " 20/h ",
+			expected: Limit{Requests: 20, Period: time.Hour},
+		},
+		{
+			name:        "Negative: Refill interval below one nanosecond",
+			value:       "1000/999ns",
+			expectError: true,
+		},
+		{
+			name:        "Negative: Missing period",
+			value:       "20",
+			expectError: true,
+		},
+		{
+			name:        "Negative: Unknown unit",
+			value:       "10/fortnight",
+			expectError: true,
+		},
+		{
+			name:        "Negative: Negative requests",
+			value:       "-1/m",
+			expectError: true,
+		},
+		{
+			name:        "Negative: Zero period",
+			value:       "10/0s",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var limit Limit
+			err := limit.Decode(tt.value)
+			if tt.expectError {
+				assert.ErrorContains(t, err, "invalid rate limit")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, limit)
+		})
+	}
+}
+
+func TestLimit_Enabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    Limit
+		expected bool
+	}{
+		{name: "Positive: Requests per period", limit: Limit{Requests: 10, Period: time.Minute}, expected: true},
+		{name: "Negative: Zero value", limit: Limit{}, expected: false},
+		{name: "Negative: Zero refill interval", limit: Limit{Requests: 10, Period: 5}, expected: false},
+		{name: "Edge: One nanosecond per request", limit: Limit{Requests: 10, Period: 10}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.limit.Enabled())
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	limit := Limit{Requests: 3, Period: 30 * time.Second}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	take := func(key string) Result {
+		result, err := store.Take(ctx, key, limit)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("Positive: Burst through the quota", func(t *testing.T) {
+		for remaining := 2; remaining >= 0; remaining-- {
+			result := take("client")
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, remaining, result.Remaining)
+			assert.Zero(t, result.RetryAfter)
+		}
+	})
+
+	t.Run("Negative: Empty bucket", func(t *testing.T) {
+		result := take("client")
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, 10*time.Second, result.RetryAfter)
+		assert.Equal(t, 30*time.Second, result.Reset)
+	})
+
+	t.Run("Positive: Other keys have their own bucket", func(t *testing.T) {
+		result := take("other")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining)
+	})
+
+	t.Run("Edge: One token refills per interval", func(t *testing.T) {
+		now = now.Add(10 * time.Second)
+		result := take("client")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, 30*time.Second, result.Reset)
+
+		result = take("client")
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 10*time.Second, result.RetryAfter)
+	})
+
+	t.Run("Edge: Bucket is full again after the period", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		result := take("client")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining)
+		assert.Equal(t, 10*time.Second, result.Reset)
+	})
+}
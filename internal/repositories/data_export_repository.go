@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"gorm.io/gorm"
+)
+
+type DataExportRepository interface {
+	Create(ctx context.Context, export *models.DataExport) error
+	FindByID(ctx context.Context, id string) (*models.DataExport, error)
+	FindLatestByUserID(ctx context.Context, userID uint) (*models.DataExport, error)
+	FindExpired(ctx context.Context, now time.Time) ([]models.DataExport, error)
+	Update(ctx context.Context, export *models.DataExport) error
+}
+
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+func NewDataExportRepository(db *gorm.DB) DataExportRepository {
+	return &dataExportRepository{db}
+}
+
+func (r *dataExportRepository) Create(ctx context.Context, export *models.DataExport) error {
+	return r.db.WithContext(ctx).Create(export).Error
+}
+
+func (r *dataExportRepository) FindByID(ctx context.Context, id string) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&export).Error
+	return &export, err
+}
+
+func (r *dataExportRepository) FindLatestByUserID(ctx context.Context, userID uint) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at desc").First(&export).Error
+	return &export, err
+}
+
+// FindExpired returns the exports whose archive expired but is still on disk.
+func (r *dataExportRepository) FindExpired(ctx context.Context, now time.Time) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	err := r.db.WithContext(ctx).Where("file_path <> '' AND expires_at < ?", now).Find(&exports).Error
+	return exports, err
+}
+
+func (r *dataExportRepository) Update(ctx context.Context, export *models.DataExport) error {
+	return r.db.WithContext(ctx).Save(export).Error
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, attempt *models.LoginAttempt) error
+	CountFailuresByIPSince(ctx context.Context, ipAddress string, since time.Time) (int64, error)
+	FindByUserID(ctx context.Context, userID uint, limit int) ([]models.LoginAttempt, error)
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db}
+}
+
+func (r *loginAttemptRepository) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	return r.db.WithContext(ctx).Create(attempt).Error
+}
+
+func (r *loginAttemptRepository) CountFailuresByIPSince(ctx context.Context, ipAddress string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("ip_address = ? AND success = ? AND created_at > ?", ipAddress, false, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *loginAttemptRepository) FindByUserID(ctx context.Context, userID uint, limit int) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at desc").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
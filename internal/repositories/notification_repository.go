@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository interface {
+	FindPreference(ctx context.Context, userID uint) (*models.NotificationPreference, error)
+	SavePreference(ctx context.Context, pref *models.NotificationPreference) error
+	ClaimLog(ctx context.Context, entry *models.NotificationLog) (bool, error)
+	ReleaseLog(ctx context.Context, entry *models.NotificationLog) error
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db}
+}
+
+func (r *notificationRepository) FindPreference(ctx context.Context, userID uint) (*models.NotificationPreference, error) {
+	var pref models.NotificationPreference
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&pref).Error
+	return &pref, err
+}
+
+func (r *notificationRepository) SavePreference(ctx context.Context, pref *models.NotificationPreference) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"language", "payment_success", "payment_failed", "payment_refunded", "updated_at"}),
+	}).Create(pref).Error
+}
+
+// ClaimLog inserts the log entry and reports false when the same
+// notification has been logged already.
+func (r *notificationRepository) ClaimLog(ctx context.Context, entry *models.NotificationLog) (bool, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseLog removes a claimed entry again so a failed delivery can be
+// retried on the next webhook.
+func (r *notificationRepository) ReleaseLog(ctx context.Context, entry *models.NotificationLog) error {
+	return r.db.WithContext(ctx).Delete(entry).Error
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReportRepository interface {
+	AggregateTransactions(ctx context.Context, start, end time.Time, location *time.Location) ([]models.DailyTransactionSummary, error)
+	ReplaceDailySummaries(ctx context.Context, reportDate string, summaries []models.DailyTransactionSummary) error
+	FindDailySummaries(ctx context.Context, fromDate, toDate string) ([]models.DailyTransactionSummary, error)
+}
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db}
+}
+
+// AggregateTransactions groups the transactions created in [start, end) by
+// day in location, payment type and status, in a single query.
+func (r *reportRepository) AggregateTransactions(ctx context.Context, start, end time.Time, location *time.Location) ([]models.DailyTransactionSummary, error) {
+	var day clause.Expr
+	if r.db.Dialector.Name() == "postgres" {
+		day = gorm.Expr("to_char(created_at AT TIME ZONE ?, 'YYYY-MM-DD')", location.String())
+	} else {
+		// note : SQLite has no time zone data, the offset at start is used for
+		// the whole range, which is exact for zones without daylight saving
+		_, offset := start.In(location).Zone()
+		day = gorm.Expr("date(created_at, ?)", fmt.Sprintf("%+d seconds", offset))
+	}
+
+	var summaries []models.DailyTransactionSummary
+	err := r.db.WithContext(ctx).Model(&models.Transaction{}).
+		Select("? AS report_date, COALESCE(payment_type, '') AS payment_type, status, COUNT(*) AS transaction_count, COALESCE(SUM(amount), 0) AS total_amount", day).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group("report_date, COALESCE(payment_type, ''), status").
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+// ReplaceDailySummaries stores the summaries of reportDate and drops rows of
+// combinations that no longer occur. Rows are upserted, so instances that
+// materialize the same day at the same time do not trip over the unique key.
+func (r *reportRepository) ReplaceDailySummaries(ctx context.Context, reportDate string, summaries []models.DailyTransactionSummary) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stale := tx.Where("report_date = ?", reportDate)
+		for _, summary := range summaries {
+			stale = stale.Not("payment_type = ? AND status = ?", summary.PaymentType, summary.Status)
+		}
+		if err := stale.Delete(&models.DailyTransactionSummary{}).Error; err != nil {
+			return err
+		}
+		if len(summaries) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "report_date"}, {Name: "payment_type"}, {Name: "status"}},
+			DoUpdates: clause.AssignmentColumns([]string{"transaction_count", "total_amount", "updated_at"}),
+		}).Create(&summaries).Error
+	})
+}
+
+func (r *reportRepository) FindDailySummaries(ctx context.Context, fromDate, toDate string) ([]models.DailyTransactionSummary, error) {
+	var summaries []models.DailyTransactionSummary
+	err := r.db.WithContext(ctx).Where("report_date >= ? AND report_date <= ?", fromDate, toDate).
+		Order("report_date asc").Order("payment_type asc").
+		Find(&summaries).Error
+	return summaries, err
+}
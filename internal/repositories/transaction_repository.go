@@ -1,15 +1,24 @@
 package repository
 
 import (
+	"context"
+	"sort"
+	"time"
+
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	"gorm.io/gorm"
 )
 
 type TransactionRepository interface {
-	Create(transaction *models.Transaction) error
-	FindByID(id string) (*models.Transaction, error)
-	Update(transaction *models.Transaction) error
-	FindByUserID(userID uint) ([]models.Transaction, error)
+	Create(ctx context.Context, transaction *models.Transaction) error
+	FindByID(ctx context.Context, id string) (*models.Transaction, error)
+	Update(ctx context.Context, transaction *models.Transaction) error
+	CountByUserID(ctx context.Context, userID uint) (int64, error)
+	FindByFilter(ctx context.Context, filter *models.TransactionFilter) ([]models.Transaction, error)
+	CountByFilter(ctx context.Context, filter *models.TransactionFilter) (int64, error)
+	StreamExportRows(ctx context.Context, filter *models.TransactionFilter, fn func(row *models.TransactionExportRow) error) error
+	AddStatusHistory(ctx context.Context, entry *models.TransactionStatusHistory) error
+	FindStatusHistoryByUserID(ctx context.Context, userID uint, until time.Time, afterID uint, limit int) ([]models.TransactionStatusHistory, error)
 	GetDB() *gorm.DB
 }
 
@@ -25,22 +34,162 @@ func (r *transactionRepository) GetDB() *gorm.DB {
 	return r.db
 }
 
-func (r *transactionRepository) Create(transaction *models.Transaction) error {
-	return r.db.Create(transaction).Error
+func (r *transactionRepository) Create(ctx context.Context, transaction *models.Transaction) error {
+	return r.db.WithContext(ctx).Create(transaction).Error
 }
 
-func (r *transactionRepository) FindByID(id string) (*models.Transaction, error) {
+func (r *transactionRepository) FindByID(ctx context.Context, id string) (*models.Transaction, error) {
 	var transaction models.Transaction
-	err := r.db.Preload("User").Preload("Items").Where("id = ?", id).First(&transaction).Error
+	err := r.db.WithContext(ctx).Preload("User").Preload("Items").Where("id = ?", id).First(&transaction).Error
 	return &transaction, err
 }
 
-func (r *transactionRepository) Update(transaction *models.Transaction) error {
-	return r.db.Save(transaction).Error
+func (r *transactionRepository) Update(ctx context.Context, transaction *models.Transaction) error {
+	return r.db.WithContext(ctx).Save(transaction).Error
+}
+
+func (r *transactionRepository) CountByUserID(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Transaction{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *transactionRepository) AddStatusHistory(ctx context.Context, entry *models.TransactionStatusHistory) error {
+	return r.db.WithContext(ctx).Create(entry).Error
 }
 
-func (r *transactionRepository) FindByUserID(userID uint) ([]models.Transaction, error) {
+// FindStatusHistoryByUserID returns one page of the status changes of the
+// user's transactions recorded before until, in ID order after afterID.
+func (r *transactionRepository) FindStatusHistoryByUserID(ctx context.Context, userID uint, until time.Time, afterID uint, limit int) ([]models.TransactionStatusHistory, error) {
+	var history []models.TransactionStatusHistory
+	err := r.db.WithContext(ctx).Joins("JOIN transactions ON transactions.id = transaction_status_histories.transaction_id").
+		Where("transactions.user_id = ? AND transaction_status_histories.created_at < ? AND transaction_status_histories.id > ?", userID, until, afterID).
+		Order("transaction_status_histories.id asc").
+		Limit(limit).
+		Find(&history).Error
+	return history, err
+}
+
+// FindByFilter returns one page of transactions with their items, ordered by
+// filter.Sort and starting after filter.After when a cursor is given.
+func (r *transactionRepository) FindByFilter(ctx context.Context, filter *models.TransactionFilter) ([]models.Transaction, error) {
+	query := applyTransactionFilter(r.db.WithContext(ctx).Model(&models.Transaction{}), filter)
+
+	switch filter.Sort {
+	case models.HistorySortCreatedAtAsc:
+		if c := filter.After; c != nil {
+			query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", c.CreatedAt, c.CreatedAt, c.ID)
+		}
+		query = query.Order("created_at asc").Order("id asc")
+	case models.HistorySortAmountDesc:
+		if c := filter.After; c != nil {
+			query = query.Where("(amount < ? OR (amount = ? AND id < ?))", c.Amount, c.Amount, c.ID)
+		}
+		query = query.Order("amount desc").Order("id desc")
+	case models.HistorySortAmountAsc:
+		if c := filter.After; c != nil {
+			query = query.Where("(amount > ? OR (amount = ? AND id > ?))", c.Amount, c.Amount, c.ID)
+		}
+		query = query.Order("amount asc").Order("id asc")
+	default:
+		if c := filter.After; c != nil {
+			query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", c.CreatedAt, c.CreatedAt, c.ID)
+		}
+		query = query.Order("created_at desc").Order("id desc")
+	}
+
 	var transactions []models.Transaction
-	err := r.db.Preload("User").Preload("Items").Where("user_id = ?", userID).Order("created_at desc").Find(&transactions).Error
+	err := query.Preload("Items").Limit(filter.Limit).Find(&transactions).Error
 	return transactions, err
 }
+
+func (r *transactionRepository) CountByFilter(ctx context.Context, filter *models.TransactionFilter) (int64, error) {
+	var count int64
+	err := applyTransactionFilter(r.db.WithContext(ctx).Model(&models.Transaction{}), filter).Count(&count).Error
+	return count, err
+}
+
+func applyTransactionFilter(query *gorm.DB, filter *models.TransactionFilter) *gorm.DB {
+	if filter.UserID != nil {
+		query = query.Where("transactions.user_id = ?", *filter.UserID)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("transactions.status IN ?", filter.Statuses)
+	}
+	if filter.From != nil {
+		query = query.Where("transactions.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("transactions.created_at < ?", *filter.To)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("transactions.amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("transactions.amount <= ?", *filter.MaxAmount)
+	}
+	if filter.PaymentType != "" {
+		query = query.Where("transactions.payment_type = ?", filter.PaymentType)
+	}
+	return query
+}
+
+// exportPageSize is the number of transactions, with their items, read per
+// query by StreamExportRows.
+const exportPageSize = 500
+
+// StreamExportRows calls fn for every filtered transaction joined with its
+// items, so exports of any size run in constant memory. Transactions are read
+// in keyset pages and fn only runs once a page has been read, so a slow client
+// never holds a database connection; SQLite has just one.
+func (r *transactionRepository) StreamExportRows(ctx context.Context, filter *models.TransactionFilter, fn func(row *models.TransactionExportRow) error) error {
+	page := *filter
+	page.After = nil
+	page.Limit = exportPageSize
+	for {
+		transactions, err := r.FindByFilter(ctx, &page)
+		if err != nil {
+			return err
+		}
+		for i := range transactions {
+			if err := emitExportRows(&transactions[i], fn); err != nil {
+				return err
+			}
+		}
+		if len(transactions) < exportPageSize {
+			return nil
+		}
+		last := transactions[len(transactions)-1]
+		page.After = &models.TransactionCursor{CreatedAt: last.CreatedAt, Amount: last.Amount, ID: last.ID}
+	}
+}
+
+// emitExportRows calls fn once per item of tx, or once with empty item
+// columns for a transaction without items.
+func emitExportRows(tx *models.Transaction, fn func(row *models.TransactionExportRow) error) error {
+	row := models.TransactionExportRow{
+		TransactionID: tx.ID,
+		UserID:        tx.UserID,
+		Amount:        tx.Amount,
+		Status:        tx.Status,
+		PaymentType:   tx.PaymentType,
+		CreatedAt:     tx.CreatedAt,
+		UpdatedAt:     tx.UpdatedAt,
+	}
+	if len(tx.Items) == 0 {
+		return fn(&row)
+	}
+
+	sort.Slice(tx.Items, func(i, j int) bool { return tx.Items[i].ID < tx.Items[j].ID })
+	for i := range tx.Items {
+		item := &tx.Items[i]
+		row.ItemID = &item.ItemID
+		row.ItemName = &item.Name
+		row.ItemPrice = &item.Price
+		row.ItemQuantity = &item.Quantity
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
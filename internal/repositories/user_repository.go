@@ -1,14 +1,20 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
-	Create(user *models.User) error
-	FindByUsername(username string) (*models.User, error)
-	FindByID(id uint) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByID(ctx context.Context, id uint) (*models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Anonymize(ctx context.Context, user *models.User) error
 }
 
 type userRepository struct {
@@ -19,18 +25,75 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	return &user, err
 }
 
-func (r *userRepository) FindByUsername(username string) (*models.User, error) {
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("username = ?", username).First(&user).Error
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
 	return &user, err
 }
 
-func (r *userRepository) FindByID(id uint) (*models.User, error) {
+func (r *userRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := r.db.WithContext(ctx).First(&user, id).Error
 	return &user, err
 }
+
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
+}
+
+// Anonymize scrubs the user's personal data and soft deletes the account in a
+// single database transaction. Transactions keep pointing at the user ID so
+// payment history stays intact for accounting.
+func (r *userRepository) Anonymize(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		placeholder := fmt.Sprintf("deleted-user-%d", user.ID)
+		placeholderEmail := placeholder + "@deleted.invalid"
+
+		updates := map[string]interface{}{
+			"full_name":          "Deleted User",
+			"username":           placeholder,
+			"email":              placeholderEmail,
+			"password":           "",
+			"address":            "",
+			"phone_number":       "",
+			"city":               "",
+			"postal_code":        "",
+			"token_version":      user.TokenVersion + 1,
+			"two_factor_enabled": false,
+			"totp_secret":        "",
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.LoginAttempt{}).Where("user_id = ?", user.ID).Update("username", placeholder).Error; err != nil {
+			return err
+		}
+		// note : notification logs are kept so a re-sent webhook still finds
+		// the notification as already sent
+		if err := tx.Model(&models.NotificationLog{}).Where("user_id = ?", user.ID).Update("recipient", placeholderEmail).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.NotificationPreference{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.User{}, user.ID).Error
+	})
+}
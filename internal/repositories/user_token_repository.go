@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	Consume(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error)
+	InvalidateByUser(ctx context.Context, userID uint, purpose string) error
+	FindLatestByUser(ctx context.Context, userID uint, purpose string) (*models.UserToken, error)
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// Consume marks a still valid token as used and returns it. The conditional
+// update makes sure a token can only be redeemed once, even under concurrent
+// requests.
+func (r *userTokenRepository) Consume(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var token models.UserToken
+	err := r.db.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, tokenHash).First(&token).Error
+	return &token, err
+}
+
+func (r *userTokenRepository) InvalidateByUser(ctx context.Context, userID uint, purpose string) error {
+	return r.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+func (r *userTokenRepository) FindLatestByUser(ctx context.Context, userID uint, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.WithContext(ctx).Where("user_id = ? AND purpose = ?", userID, purpose).Order("created_at desc").First(&token).Error
+	return &token, err
+}
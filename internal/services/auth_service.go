@@ -1,36 +1,83 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"gorm.io/gorm"
 )
 
 type AuthService interface {
-	Register(req *models.RegisterRequest) (*models.User, error)
-	Login(req *models.LoginRequest) (*models.LoginResponse, error)
-	ValidateToken(tokenString string) (uint, error)
+	Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error)
+	Login(ctx context.Context, req *models.LoginRequest, clientIP string) (*models.LoginResponse, error)
+	ValidateToken(ctx context.Context, tokenString string) (uint, error)
+	ForgotPassword(ctx context.Context, req *models.ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error
+	VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) error
+	ResendVerification(ctx context.Context, userID uint) error
+	VerifyTwoFactorLogin(ctx context.Context, req *models.TwoFactorLoginRequest, clientIP string) (*models.LoginResponse, error)
+	EnrollTwoFactor(ctx context.Context, userID uint) (*models.TwoFactorEnrollResponse, error)
+	ConfirmTwoFactor(ctx context.Context, userID uint, req *models.TwoFactorCodeRequest) (*models.TwoFactorConfirmResponse, error)
+	DisableTwoFactor(ctx context.Context, userID uint, req *models.TwoFactorCodeRequest) error
+	UnlockUser(ctx context.Context, userID uint) error
 }
 
+var (
+	ErrUsernameTaken            = apperror.Conflict("USERNAME_TAKEN", "username is already taken", apperror.FieldError{Field: "username", Message: "is already taken"})
+	ErrEmailTaken               = apperror.Conflict("EMAIL_TAKEN", "email address is already registered", apperror.FieldError{Field: "email", Message: "is already registered"})
+	ErrInvalidCredentials       = apperror.Unauthorized("INVALID_CREDENTIALS", "invalid username or password")
+	ErrInvalidToken             = apperror.Unauthorized("INVALID_TOKEN", "invalid or expired token")
+	ErrInvalidResetToken        = apperror.Validation("INVALID_RESET_TOKEN", "invalid or expired reset token")
+	ErrInvalidVerificationToken = apperror.Validation("INVALID_VERIFICATION_TOKEN", "invalid or expired verification token")
+	ErrEmailAlreadyVerified     = apperror.Conflict("EMAIL_ALREADY_VERIFIED", "email address is already verified")
+	ErrVerificationThrottled    = apperror.TooManyRequests("VERIFICATION_THROTTLED", "verification email was sent recently, please wait before requesting another")
+	ErrInvalidChallenge         = apperror.Unauthorized("INVALID_TWO_FACTOR_CHALLENGE", "invalid or expired two-factor challenge")
+	ErrInvalidTwoFactorCode     = apperror.Validation("INVALID_TWO_FACTOR_CODE", "invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled  = apperror.Conflict("TWO_FACTOR_ALREADY_ENABLED", "two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled     = apperror.Conflict("TWO_FACTOR_NOT_ENROLLED", "two-factor authentication has not been set up")
+	// ErrLoginThrottled is returned with RetryAfter set when a login is
+	// refused before the password is checked, either because the account is
+	// locked or the client IP has too many recent failures.
+	ErrLoginThrottled = apperror.TooManyRequests("LOGIN_THROTTLED", "too many failed login attempts, please try again later")
+)
+
+const recoveryCodeCount = 10
+
 type authService struct {
+	loginLockout
 	userRepo     repository.UserRepository
+	tokenRepo    repository.UserTokenRepository
+	recoveryRepo repository.RecoveryCodeRepository
+	mailSender   MailSender
+	totpBox      *utils.SecretBox
 	jwtSecretKey string
 	cfg          *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepository, cfg *config.Config) AuthService {
+func NewAuthService(userRepo repository.UserRepository, tokenRepo repository.UserTokenRepository, recoveryRepo repository.RecoveryCodeRepository, attemptRepo repository.LoginAttemptRepository, mailSender MailSender, totpBox *utils.SecretBox, cfg *config.Config) AuthService {
 	return &authService{
+		loginLockout: loginLockout{userRepo: userRepo, attemptRepo: attemptRepo, cfg: cfg},
 		userRepo:     userRepo,
+		tokenRepo:    tokenRepo,
+		recoveryRepo: recoveryRepo,
+		mailSender:   mailSender,
+		totpBox:      totpBox,
 		cfg:          cfg,
 		jwtSecretKey: cfg.JWTSecretKey,
 	}
 }
 
-func (s *authService) Register(req *models.RegisterRequest) (*models.User, error) {
-	hashedPassword, err := utils.HashPassword(req.Password)
+func (s *authService) Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error) {
+	hashedPassword, err := hashPassword(ctx, req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -44,45 +91,412 @@ func (s *authService) Register(req *models.RegisterRequest) (*models.User, error
 		PhoneNumber: req.PhoneNumber,
 		City:        req.City,
 		PostalCode:  req.PostalCode,
+		Role:        models.RoleUser,
 	}
 
-	if err := s.userRepo.Create(newUser); err != nil {
+	if err := s.userRepo.Create(ctx, newUser); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, s.duplicateUserError(ctx, req)
+		}
 		return nil, err
 	}
+
+	// note : registration succeeds even if the mail is lost, the user can request a resend
+	if err := s.sendVerificationEmail(ctx, newUser); err != nil {
+		logging.FromContext(ctx).Error("failed to send verification email", "user_id", newUser.ID, "error", err)
+	}
 	return newUser, nil
 }
 
-func (s *authService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
-	user, err := s.userRepo.FindByUsername(req.Username)
+// duplicateUserError tells which unique field made the insert fail.
+func (s *authService) duplicateUserError(ctx context.Context, req *models.RegisterRequest) error {
+	if _, err := s.userRepo.FindByUsername(ctx, req.Username); err == nil {
+		return ErrUsernameTaken
+	}
+	return ErrEmailTaken
+}
+
+func (s *authService) Login(ctx context.Context, req *models.LoginRequest, clientIP string) (*models.LoginResponse, error) {
+	// note : throttling is checked before bcrypt so hammering the endpoint stays cheap
+	ipFailures, err := s.attemptRepo.CountFailuresByIPSince(ctx, clientIP, time.Now().Add(-s.cfg.LoginIPWindow))
 	if err != nil {
-		return nil, errors.New("invalid username or password")
+		return nil, err
+	}
+	if ipFailures >= s.cfg.LoginIPMaxFailures {
+		s.recordAttempt(ctx, req.Username, nil, clientIP, models.LoginAttemptReasonIPThrottled)
+		return nil, ErrLoginThrottled.WithRetryAfter(s.cfg.LoginIPWindow)
 	}
 
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		return nil, errors.New("invalid username or password")
+	user, err := s.userRepo.FindByUsername(ctx, req.Username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.recordAttempt(ctx, req.Username, nil, clientIP, models.LoginAttemptReasonUnknownUser)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkLoginAllowed(ctx, user, clientIP); err != nil {
+		return nil, err
 	}
 
-	token, err := utils.GenerateToken(user.ID, s.cfg.JWTSecretKey, s.cfg.JWTExpiration)
+	if !checkPassword(ctx, req.Password, user.Password) {
+		if err := s.registerLoginFailure(ctx, user, clientIP, models.LoginAttemptReasonInvalidCredentials); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.TwoFactorEnabled {
+		challenge, err := utils.GenerateChallengeToken(user.ID, user.TokenVersion, s.cfg.JWTSecretKey, s.cfg.TwoFactorChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &models.LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	if err := s.registerLoginSuccess(ctx, user, clientIP); err != nil {
+		return nil, err
+	}
+	return s.issueLoginResponse(user)
+}
+
+func (s *authService) UnlockUser(ctx context.Context, userID uint) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return translateNotFound(err, ErrUserNotFound)
+	}
+
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	return s.userRepo.Update(ctx, user)
+}
+
+func (s *authService) issueLoginResponse(user *models.User) (*models.LoginResponse, error) {
+	token, err := utils.GenerateToken(user.ID, user.TokenVersion, s.cfg.JWTSecretKey, s.cfg.JWTExpiration)
 	if err != nil {
 		return nil, err
 	}
 
 	loginResponse := &models.LoginResponse{
 		Token: token,
-		User: models.UserResponse{
-			ID:          user.ID,
-			FullName:    user.FullName,
-			Username:    user.Username,
-			Email:       user.Email,
-			Address:     user.Address,
-			PhoneNumber: user.PhoneNumber,
-			City:        user.City,
-			PostalCode:  user.PostalCode,
+		User: &models.UserResponse{
+			ID:            user.ID,
+			FullName:      user.FullName,
+			Username:      user.Username,
+			Email:         user.Email,
+			Address:       user.Address,
+			PhoneNumber:   user.PhoneNumber,
+			City:          user.City,
+			PostalCode:    user.PostalCode,
+			EmailVerified: user.EmailVerifiedAt != nil,
 		},
 	}
 	return loginResponse, nil
 }
 
-func (s *authService) ValidateToken(tokenString string) (uint, error) {
-	return utils.ValidateToken(tokenString, s.cfg.JWTSecretKey)
+func (s *authService) ValidateToken(ctx context.Context, tokenString string) (uint, error) {
+	claims, err := utils.ParseToken(tokenString, s.cfg.JWTSecretKey)
+	if err != nil {
+		return 0, ErrInvalidToken.Wrap(err)
+	}
+
+	if claims.Purpose != "" {
+		return 0, ErrInvalidToken.Wrap(errors.New("token cannot be used for authentication"))
+	}
+
+	user, err := s.userRepo.FindByID(ctx, claims.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrInvalidToken.Wrap(errors.New("user not found"))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// note : TokenVersion is bumped on password reset, revoking older sessions
+	if claims.TokenVersion != user.TokenVersion {
+		return 0, ErrInvalidToken.Wrap(errors.New("token has been revoked"))
+	}
+
+	return claims.UserID, nil
+}
+
+func (s *authService) ForgotPassword(ctx context.Context, req *models.ForgotPasswordRequest) error {
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		// note : never reveal whether an email is registered
+		logging.FromContext(ctx).Info("password reset requested for unknown email")
+		return nil
+	}
+
+	if err := s.tokenRepo.InvalidateByUser(ctx, user.ID, models.UserTokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	rawToken, err := s.issueToken(ctx, user.ID, models.UserTokenPurposePasswordReset, s.cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	resetLink := fmt.Sprintf("%s/reset-password?token=%s", s.cfg.AppBaseURL, rawToken)
+	return s.mailSender.Send(ctx, &MailMessage{
+		To:      []string{user.Email},
+		Subject: "Reset your password",
+		TextBody: fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. It expires in %s.\n\n%s\n\nIf you did not request a password reset, you can ignore this email.\n",
+			user.FullName, s.cfg.PasswordResetTTL, resetLink),
+	})
+}
+
+func (s *authService) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	token, err := s.tokenRepo.Consume(ctx, models.UserTokenPurposePasswordReset, utils.HashToken(req.Token))
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := hashPassword(ctx, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hashedPassword
+	user.TokenVersion++
+	return s.userRepo.Update(ctx, user)
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) error {
+	token, err := s.tokenRepo.Consume(ctx, models.UserTokenPurposeEmailVerification, utils.HashToken(req.Token))
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return s.userRepo.Update(ctx, user)
+}
+
+func (s *authService) ResendVerification(ctx context.Context, userID uint) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return translateNotFound(err, ErrUserNotFound)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	latest, err := s.tokenRepo.FindLatestByUser(ctx, user.ID, models.UserTokenPurposeEmailVerification)
+	if err == nil && time.Since(latest.CreatedAt) < s.cfg.EmailVerifyResend {
+		return ErrVerificationThrottled
+	}
+
+	if err := s.tokenRepo.InvalidateByUser(ctx, user.ID, models.UserTokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *authService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	rawToken, err := s.issueToken(ctx, user.ID, models.UserTokenPurposeEmailVerification, s.cfg.EmailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	verifyLink := fmt.Sprintf("%s/verify-email?token=%s", s.cfg.AppBaseURL, rawToken)
+	return s.mailSender.Send(ctx, &MailMessage{
+		To:      []string{user.Email},
+		Subject: "Verify your email address",
+		TextBody: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address to start making payments. The link expires in %s.\n\n%s\n",
+			user.FullName, s.cfg.EmailVerifyTTL, verifyLink),
+	})
+}
+
+// issueToken stores the hash of a new one-time token and returns the raw value
+// that is sent to the user.
+func (s *authService) issueToken(ctx context.Context, userID uint, purpose string, ttl time.Duration) (string, error) {
+	rawToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	token := &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+func (s *authService) VerifyTwoFactorLogin(ctx context.Context, req *models.TwoFactorLoginRequest, clientIP string) (*models.LoginResponse, error) {
+	claims, err := utils.ParseToken(req.ChallengeToken, s.cfg.JWTSecretKey)
+	if err != nil || claims.Purpose != utils.TokenPurposeTwoFactor {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.FindByID(ctx, claims.UserID)
+	if err != nil || claims.TokenVersion != user.TokenVersion || !user.TwoFactorEnabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.checkLoginAllowed(ctx, user, clientIP); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTwoFactorCode(ctx, user, req.Code, true); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if err := s.registerLoginFailure(ctx, user, clientIP, models.LoginAttemptReasonInvalidTwoFactor); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.registerLoginSuccess(ctx, user, clientIP); err != nil {
+		return nil, err
+	}
+	return s.issueLoginResponse(user)
+}
+
+func (s *authService) EnrollTwoFactor(ctx context.Context, userID uint) (*models.TwoFactorEnrollResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, translateNotFound(err, ErrUserNotFound)
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.totpBox.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	// note : the secret only becomes active once a valid code is confirmed
+	user.TOTPSecret = sealed
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.cfg.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *authService) ConfirmTwoFactor(ctx context.Context, userID uint, req *models.TwoFactorCodeRequest) (*models.TwoFactorConfirmResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, translateNotFound(err, ErrUserNotFound)
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	if err := s.checkTwoFactorCode(ctx, user, req.Code, false); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRandomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(code))
+	}
+
+	if err := s.recoveryRepo.ReplaceForUser(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	user.TwoFactorEnabled = true
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *authService) DisableTwoFactor(ctx context.Context, userID uint, req *models.TwoFactorCodeRequest) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return translateNotFound(err, ErrUserNotFound)
+	}
+
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnrolled
+	}
+
+	if err := s.checkTwoFactorCode(ctx, user, req.Code, true); err != nil {
+		return err
+	}
+
+	if err := s.recoveryRepo.DeleteByUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	user.TwoFactorEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	return s.userRepo.Update(ctx, user)
+}
+
+// checkTwoFactorCode accepts a TOTP code or, when allowRecovery is set, one
+// of the user's unused recovery codes. Accepted TOTP steps are persisted so
+// the same code cannot be replayed.
+func (s *authService) checkTwoFactorCode(ctx context.Context, user *models.User, code string, allowRecovery bool) error {
+	secret, plaintext, err := s.totpBox.Open(user.TOTPSecret)
+	if err != nil {
+		return fmt.Errorf("could not decrypt totp secret: %w", err)
+	}
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now(), user.TOTPLastStep); ok {
+		user.TOTPLastStep = step
+		// note : secrets enrolled before encryption are sealed on first use
+		if plaintext {
+			if user.TOTPSecret, err = s.totpBox.Seal(secret); err != nil {
+				return err
+			}
+		}
+		return s.userRepo.Update(ctx, user)
+	}
+
+	if allowRecovery {
+		if err := s.recoveryRepo.Consume(ctx, user.ID, utils.HashToken(strings.TrimSpace(code))); err == nil {
+			return nil
+		}
+	}
+
+	return ErrInvalidTwoFactorCode
 }
@@ -0,0 +1,582 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/background"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"gorm.io/gorm"
+)
+
+type DataExportService interface {
+	RequestExport(ctx context.Context, userID uint) (*models.DataExport, error)
+	GetExport(ctx context.Context, userID uint, exportID string) (*models.DataExport, error)
+	RemoveExpired(ctx context.Context) error
+	StartCleanupJob(ctx context.Context)
+}
+
+var (
+	ErrExportNotFound  = apperror.NotFound("EXPORT_NOT_FOUND", "export not found")
+	ErrExportExpired   = apperror.Gone("EXPORT_EXPIRED", "export has expired, please request a new one")
+	ErrExportNotReady  = apperror.Conflict("EXPORT_NOT_READY", "export is not ready yet")
+	ErrExportPending   = apperror.Conflict("EXPORT_PENDING", "an export is already being prepared")
+	ErrExportThrottled = apperror.TooManyRequests("EXPORT_THROTTLED", "an export was requested recently, please try again later")
+)
+
+const (
+	// maxConcurrentExports bounds the number of archives built in the
+	// background at the same time.
+	maxConcurrentExports = 2
+	// exportBatchSize is the number of transactions, with their items, loaded
+	// per query while collecting an export.
+	exportBatchSize = 500
+)
+
+type dataExportService struct {
+	exportRepo  repository.DataExportRepository
+	userRepo    repository.UserRepository
+	txRepo      repository.TransactionRepository
+	attemptRepo repository.LoginAttemptRepository
+	workers     *background.Group
+	cfg         *config.Config
+	slots       chan struct{}
+}
+
+func NewDataExportService(exportRepo repository.DataExportRepository, userRepo repository.UserRepository, txRepo repository.TransactionRepository, attemptRepo repository.LoginAttemptRepository, workers *background.Group, cfg *config.Config) DataExportService {
+	return &dataExportService{
+		exportRepo:  exportRepo,
+		userRepo:    userRepo,
+		txRepo:      txRepo,
+		attemptRepo: attemptRepo,
+		workers:     workers,
+		cfg:         cfg,
+		slots:       make(chan struct{}, maxConcurrentExports),
+	}
+}
+
+// RequestExport builds the archive right away for small accounts. Accounts
+// with more than EXPORT_SYNC_MAX_TRANSACTIONS transactions are processed in
+// the background and the returned export is still pending.
+func (s *dataExportService) RequestExport(ctx context.Context, userID uint) (*models.DataExport, error) {
+	latest, err := s.exportRepo.FindLatestByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		if err := s.checkRequestAllowed(latest); err != nil {
+			return nil, err
+		}
+		if latest.Status == models.DataExportStatusPending || latest.Status == models.DataExportStatusProcessing {
+			latest.Status = models.DataExportStatusFailed
+			latest.Error = "export timed out"
+			if err := s.exportRepo.Update(ctx, latest); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	id, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.DataExport{
+		ID:     id,
+		UserID: userID,
+		Status: models.DataExportStatusPending,
+	}
+	if err := s.exportRepo.Create(ctx, export); err != nil {
+		// note : the unique index on unfinished exports settles concurrent
+		// requests that all passed checkRequestAllowed
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrExportPending
+		}
+		return nil, err
+	}
+
+	count, err := s.txRepo.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if count <= s.cfg.ExportSyncMaxTx {
+		s.process(ctx, export)
+		return export, nil
+	}
+
+	// note : the export outlives the request, it keeps the request's logger
+	// and trace but not its cancellation
+	ctx = context.WithoutCancel(ctx)
+	s.workers.Go(func() {
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(ctx, s.cfg.ExportTimeout)
+		defer cancel()
+		s.process(ctx, export)
+	})
+	return export, nil
+}
+
+// checkRequestAllowed rejects a new export while the previous one is being
+// built and allows one export per EXPORT_REQUEST_INTERVAL, since every export
+// reads the whole account. A failed export can be retried right away, and one
+// pending for longer than EXPORT_TIMEOUT is assumed lost to a restart.
+func (s *dataExportService) checkRequestAllowed(latest *models.DataExport) error {
+	age := time.Since(latest.CreatedAt)
+	switch latest.Status {
+	case models.DataExportStatusPending, models.DataExportStatusProcessing:
+		if age < s.cfg.ExportTimeout {
+			return ErrExportPending
+		}
+	case models.DataExportStatusReady:
+		if age < s.cfg.ExportRequestInterval {
+			return ErrExportThrottled.WithRetryAfter(s.cfg.ExportRequestInterval - age)
+		}
+	}
+	return nil
+}
+
+func (s *dataExportService) GetExport(ctx context.Context, userID uint, exportID string) (*models.DataExport, error) {
+	export, err := s.exportRepo.FindByID(ctx, exportID)
+	if err != nil || export.UserID != userID {
+		return nil, ErrExportNotFound
+	}
+
+	if export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt) {
+		if export.FilePath != "" {
+			os.Remove(export.FilePath)
+		}
+		return nil, ErrExportExpired
+	}
+	return export, nil
+}
+
+// RemoveExpired deletes the archives of expired exports, including those that
+// were never looked at again after they expired.
+func (s *dataExportService) RemoveExpired(ctx context.Context) error {
+	expired, err := s.exportRepo.FindExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range expired {
+		export := &expired[i]
+		if err := os.Remove(export.FilePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			logging.FromContext(ctx).Error("failed to remove expired export", "export_id", export.ID, "error", err)
+			continue
+		}
+		export.FilePath = ""
+		if err := s.exportRepo.Update(ctx, export); err != nil {
+			return err
+		}
+	}
+	if len(expired) > 0 {
+		logging.FromContext(ctx).Info("expired exports removed", "count", len(expired))
+	}
+	return nil
+}
+
+// StartCleanupJob runs RemoveExpired at startup and every
+// EXPORT_CLEANUP_INTERVAL until ctx is cancelled.
+func (s *dataExportService) StartCleanupJob(ctx context.Context) {
+	s.workers.Go(func() {
+		ticker := time.NewTicker(s.cfg.ExportCleanupInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.RemoveExpired(context.WithoutCancel(ctx)); err != nil {
+				logging.FromContext(ctx).Error("failed to remove expired exports", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (s *dataExportService) process(ctx context.Context, export *models.DataExport) {
+	export.Status = models.DataExportStatusProcessing
+	if err := s.exportRepo.Update(ctx, export); err != nil {
+		logging.FromContext(ctx).Error("failed to update export", "export_id", export.ID, "user_id", export.UserID, "error", err)
+	}
+
+	path := filepath.Join(s.cfg.ExportDir, fmt.Sprintf("export-%d-%s.zip", export.UserID, export.ID))
+	now := time.Now()
+	if err := s.writeArchive(ctx, export.UserID, path); err != nil {
+		logging.FromContext(ctx).Error("failed to build export", "export_id", export.ID, "user_id", export.UserID, "error", err)
+		os.Remove(path)
+		export.Status = models.DataExportStatusFailed
+		export.Error = err.Error()
+	} else {
+		expiresAt := now.Add(s.cfg.ExportTTL)
+		export.Status = models.DataExportStatusReady
+		export.FilePath = path
+		export.ExpiresAt = &expiresAt
+	}
+	export.CompletedAt = &now
+
+	// note : the outcome is stored even when the export ran out of time
+	if err := s.exportRepo.Update(context.WithoutCancel(ctx), export); err != nil {
+		logging.FromContext(ctx).Error("failed to update export", "export_id", export.ID, "user_id", export.UserID, "error", err)
+	}
+}
+
+type exportProfile struct {
+	ID              uint       `json:"id"`
+	FullName        string     `json:"full_name"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Address         string     `json:"address"`
+	PhoneNumber     string     `json:"phone_number"`
+	City            string     `json:"city"`
+	PostalCode      string     `json:"postal_code"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type exportItem struct {
+	ItemID   string `json:"item_id"`
+	Name     string `json:"name"`
+	Price    int64  `json:"price"`
+	Quantity int32  `json:"quantity"`
+}
+
+type exportTransaction struct {
+	OrderID    string       `json:"order_id"`
+	Amount     int64        `json:"amount"`
+	Status     string       `json:"status"`
+	PaymentURL string       `json:"payment_url"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	Items      []exportItem `json:"items"`
+}
+
+type exportStatusChange struct {
+	OrderID   string    `json:"order_id"`
+	Status    string    `json:"status"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportLogin struct {
+	IPAddress string    `json:"ip_address"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// loginHistoryLimit caps the number of login attempts included in an export.
+const loginHistoryLimit = 10000
+
+// writeArchive streams the export into a zip at path. Transactions and their
+// status history are read in pages and written as they arrive, so an account
+// of any size is exported in constant memory. Only rows created before the
+// export started are included, which keeps every file in the archive in sync.
+func (s *dataExportService) writeArchive(ctx context.Context, userID uint, path string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	attempts, err := s.attemptRepo.FindByUserID(ctx, userID, loginHistoryLimit)
+	if err != nil {
+		return err
+	}
+	generatedAt := time.Now()
+
+	profile := exportProfile{
+		ID:              user.ID,
+		FullName:        user.FullName,
+		Username:        user.Username,
+		Email:           user.Email,
+		Address:         user.Address,
+		PhoneNumber:     user.PhoneNumber,
+		City:            user.City,
+		PostalCode:      user.PostalCode,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		TwoFactor:       user.TwoFactorEnabled,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+	logins := make([]exportLogin, 0, len(attempts))
+	for _, a := range attempts {
+		logins = append(logins, exportLogin{IPAddress: a.IPAddress, Success: a.Success, Reason: a.Reason, CreatedAt: a.CreatedAt})
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	jsonFile, err := zw.Create("data.json")
+	if err != nil {
+		return err
+	}
+	data := newJSONObjectWriter(jsonFile)
+	data.Field("generated_at", generatedAt)
+	data.Field("profile", profile)
+	data.BeginArray("transactions")
+	err = s.eachTransaction(ctx, userID, generatedAt, func(tx *models.Transaction) error {
+		return data.Item(newExportTransaction(tx))
+	})
+	if err != nil {
+		return err
+	}
+	data.EndArray()
+	data.BeginArray("status_history")
+	err = s.eachStatusChange(ctx, userID, generatedAt, func(h *models.TransactionStatusHistory) error {
+		return data.Item(exportStatusChange{OrderID: h.TransactionID, Status: h.Status, Source: h.Source, CreatedAt: h.CreatedAt})
+	})
+	if err != nil {
+		return err
+	}
+	data.EndArray()
+	data.Field("login_history", logins)
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	// note : the CSVs go through utils.RowWriter, which escapes cells that a
+	// spreadsheet would run as a formula
+	err = writeCSVFile(zw, "profile.csv", func(rw utils.RowWriter) error {
+		if err := rw.WriteRow([]interface{}{"id", "full_name", "username", "email", "address", "phone_number", "city", "postal_code", "created_at"}); err != nil {
+			return err
+		}
+		return rw.WriteRow([]interface{}{profile.ID, profile.FullName, profile.Username, profile.Email, profile.Address, profile.PhoneNumber, profile.City, profile.PostalCode, profile.CreatedAt})
+	})
+	if err != nil {
+		return err
+	}
+	err = writeCSVFile(zw, "transactions.csv", func(rw utils.RowWriter) error {
+		if err := rw.WriteRow([]interface{}{"order_id", "amount", "status", "payment_url", "created_at", "updated_at"}); err != nil {
+			return err
+		}
+		return s.eachTransaction(ctx, userID, generatedAt, func(tx *models.Transaction) error {
+			return rw.WriteRow([]interface{}{tx.ID, tx.Amount, tx.Status, tx.PaymentURL, tx.CreatedAt, tx.UpdatedAt})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = writeCSVFile(zw, "transaction_items.csv", func(rw utils.RowWriter) error {
+		if err := rw.WriteRow([]interface{}{"order_id", "item_id", "name", "price", "quantity"}); err != nil {
+			return err
+		}
+		return s.eachTransaction(ctx, userID, generatedAt, func(tx *models.Transaction) error {
+			for _, item := range tx.Items {
+				if err := rw.WriteRow([]interface{}{tx.ID, item.ItemID, item.Name, item.Price, item.Quantity}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = writeCSVFile(zw, "status_history.csv", func(rw utils.RowWriter) error {
+		if err := rw.WriteRow([]interface{}{"order_id", "status", "source", "created_at"}); err != nil {
+			return err
+		}
+		return s.eachStatusChange(ctx, userID, generatedAt, func(h *models.TransactionStatusHistory) error {
+			return rw.WriteRow([]interface{}{h.TransactionID, h.Status, h.Source, h.CreatedAt})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = writeCSVFile(zw, "login_history.csv", func(rw utils.RowWriter) error {
+		if err := rw.WriteRow([]interface{}{"ip_address", "success", "reason", "created_at"}); err != nil {
+			return err
+		}
+		for _, l := range logins {
+			if err := rw.WriteRow([]interface{}{l.IPAddress, l.Success, l.Reason, l.CreatedAt}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func newExportTransaction(tx *models.Transaction) exportTransaction {
+	items := make([]exportItem, 0, len(tx.Items))
+	for _, item := range tx.Items {
+		items = append(items, exportItem{ItemID: item.ItemID, Name: item.Name, Price: item.Price, Quantity: item.Quantity})
+	}
+	return exportTransaction{
+		OrderID:    tx.ID,
+		Amount:     tx.Amount,
+		Status:     tx.Status,
+		PaymentURL: tx.PaymentURL,
+		CreatedAt:  tx.CreatedAt,
+		UpdatedAt:  tx.UpdatedAt,
+		Items:      items,
+	}
+}
+
+// eachTransaction calls fn for every transaction of the user created before
+// until, loading them with their items one page at a time.
+func (s *dataExportService) eachTransaction(ctx context.Context, userID uint, until time.Time, fn func(tx *models.Transaction) error) error {
+	filter := &models.TransactionFilter{UserID: &userID, To: &until, Limit: exportBatchSize}
+	for {
+		page, err := s.txRepo.FindByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for i := range page {
+			if err := fn(&page[i]); err != nil {
+				return err
+			}
+		}
+		if len(page) < exportBatchSize {
+			return nil
+		}
+		last := page[len(page)-1]
+		filter.After = &models.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+// eachStatusChange calls fn for every status change of the user's
+// transactions recorded before until, one page at a time.
+func (s *dataExportService) eachStatusChange(ctx context.Context, userID uint, until time.Time, fn func(h *models.TransactionStatusHistory) error) error {
+	var afterID uint
+	for {
+		page, err := s.txRepo.FindStatusHistoryByUserID(ctx, userID, until, afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		for i := range page {
+			if err := fn(&page[i]); err != nil {
+				return err
+			}
+		}
+		if len(page) < exportBatchSize {
+			return nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+func writeCSVFile(zw *zip.Writer, name string, write func(rw utils.RowWriter) error) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	rw := utils.NewCSVRowWriter(w)
+	if err := write(rw); err != nil {
+		return err
+	}
+	return rw.Close()
+}
+
+// jsonObjectWriter writes an indented JSON object one field at a time. Array
+// fields are written item by item, so they can be filled from paged queries.
+// The first error is kept, later writes are skipped and Item and Close
+// return it.
+type jsonObjectWriter struct {
+	w      *bufio.Writer
+	err    error
+	fields int
+	items  int
+}
+
+func newJSONObjectWriter(w io.Writer) *jsonObjectWriter {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("{")
+	return &jsonObjectWriter{w: bw}
+}
+
+func (j *jsonObjectWriter) Field(name string, value interface{}) {
+	j.key(name)
+	j.value(value, "  ")
+}
+
+func (j *jsonObjectWriter) BeginArray(name string) {
+	j.key(name)
+	j.write("[")
+	j.items = 0
+}
+
+func (j *jsonObjectWriter) Item(value interface{}) error {
+	if j.items > 0 {
+		j.write(",")
+	}
+	j.write("\n    ")
+	j.value(value, "    ")
+	j.items++
+	return j.err
+}
+
+func (j *jsonObjectWriter) EndArray() {
+	if j.items > 0 {
+		j.write("\n  ")
+	}
+	j.write("]")
+}
+
+func (j *jsonObjectWriter) Close() error {
+	j.write("\n}\n")
+	if j.err != nil {
+		return j.err
+	}
+	return j.w.Flush()
+}
+
+func (j *jsonObjectWriter) key(name string) {
+	if j.fields > 0 {
+		j.write(",")
+	}
+	j.fields++
+	j.write("\n  ")
+	j.value(name, "")
+	j.write(": ")
+}
+
+func (j *jsonObjectWriter) value(value interface{}, prefix string) {
+	if j.err != nil {
+		return
+	}
+	raw, err := json.MarshalIndent(value, prefix, "  ")
+	if err != nil {
+		j.err = err
+		return
+	}
+	_, j.err = j.w.Write(raw)
+}
+
+func (j *jsonObjectWriter) write(text string) {
+	if j.err != nil {
+		return
+	}
+	_, j.err = j.w.WriteString(text)
+}
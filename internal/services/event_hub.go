@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+)
+
+// EventHub is an in-process pub/sub for payment events. Every subscriber gets
+// a buffered channel; a subscriber that falls behind is dropped and its
+// channel closed instead of blocking the publisher.
+type EventHub interface {
+	Publish(event models.PaymentEvent)
+	Subscribe(filter func(models.PaymentEvent) bool) *EventSubscription
+	Unsubscribe(sub *EventSubscription)
+	// Close ends every subscription, so open streams finish on shutdown.
+	Close()
+}
+
+var (
+	ErrSubscriberTooSlow = errors.New("subscriber fell behind")
+	ErrEventHubClosed    = errors.New("event hub closed")
+)
+
+type EventSubscription struct {
+	Events <-chan models.PaymentEvent
+	events chan models.PaymentEvent
+	filter func(models.PaymentEvent) bool
+	err    error
+}
+
+// Err tells why the hub closed Events, or is nil while the subscription is
+// open or after Unsubscribe. It must only be called once Events is closed.
+func (s *EventSubscription) Err() error {
+	return s.err
+}
+
+// eventBufferSize is the number of undelivered events a subscriber may have
+// before it is considered too slow.
+const eventBufferSize = 32
+
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[*EventSubscription]struct{}
+	closed      bool
+}
+
+func NewEventHub() EventHub {
+	return &eventHub{subscribers: make(map[*EventSubscription]struct{})}
+}
+
+func (h *eventHub) Publish(event models.PaymentEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.drop(sub, ErrSubscriberTooSlow)
+		}
+	}
+}
+
+func (h *eventHub) Subscribe(filter func(models.PaymentEvent) bool) *EventSubscription {
+	events := make(chan models.PaymentEvent, eventBufferSize)
+	sub := &EventSubscription{Events: events, events: events, filter: filter}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		sub.err = ErrEventHubClosed
+		close(events)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (h *eventHub) Unsubscribe(sub *EventSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		h.drop(sub, nil)
+	}
+}
+
+func (h *eventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		h.drop(sub, ErrEventHubClosed)
+	}
+}
+
+// drop must be called with mu held.
+func (h *eventHub) drop(sub *EventSubscription, err error) {
+	delete(h.subscribers, sub)
+	sub.err = err
+	close(sub.events)
+}
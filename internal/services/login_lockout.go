@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+)
+
+// loginLockout applies the account lockout to every place a user proves they
+// know their password, so the password endpoints cannot be used to guess it
+// around the login throttle.
+type loginLockout struct {
+	userRepo    repository.UserRepository
+	attemptRepo repository.LoginAttemptRepository
+	cfg         *config.Config
+}
+
+// checkLoginAllowed enforces the account lockout and the progressive delay
+// between failed attempts (LOGIN_DELAY_BASE doubled for every failure).
+func (l *loginLockout) checkLoginAllowed(ctx context.Context, user *models.User, clientIP string) error {
+	now := time.Now()
+
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		l.recordAttempt(ctx, user.Username, &user.ID, clientIP, models.LoginAttemptReasonLocked)
+		return ErrLoginThrottled.WithRetryAfter(user.LockedUntil.Sub(now))
+	}
+
+	if user.FailedLoginAttempts > 0 && user.LastFailedLoginAt != nil && user.FailedLoginAttempts < l.cfg.LoginMaxAttempts {
+		delay := l.cfg.LoginDelayBase << (user.FailedLoginAttempts - 1)
+		if allowedAt := user.LastFailedLoginAt.Add(delay); now.Before(allowedAt) {
+			l.recordAttempt(ctx, user.Username, &user.ID, clientIP, models.LoginAttemptReasonLocked)
+			return ErrLoginThrottled.WithRetryAfter(allowedAt.Sub(now))
+		}
+	}
+	return nil
+}
+
+// registerLoginFailure counts a failed attempt. Reaching LOGIN_MAX_ATTEMPTS
+// locks the account; every further failure after a lockout doubles its
+// length up to LOGIN_LOCKOUT_MAX.
+func (l *loginLockout) registerLoginFailure(ctx context.Context, user *models.User, clientIP, reason string) error {
+	now := time.Now()
+	user.FailedLoginAttempts++
+	user.LastFailedLoginAt = &now
+
+	if over := user.FailedLoginAttempts - l.cfg.LoginMaxAttempts; over >= 0 {
+		lockout := l.cfg.LoginLockoutMax
+		if over < 32 {
+			if d := l.cfg.LoginLockoutDuration << over; d > 0 && d < lockout {
+				lockout = d
+			}
+		}
+		lockedUntil := now.Add(lockout)
+		user.LockedUntil = &lockedUntil
+		logging.FromContext(ctx).Warn("user locked after failed login attempts", "user_id", user.ID, "locked_until", lockedUntil, "failed_attempts", user.FailedLoginAttempts)
+	}
+
+	l.recordAttempt(ctx, user.Username, &user.ID, clientIP, reason)
+	return l.userRepo.Update(ctx, user)
+}
+
+func (l *loginLockout) registerLoginSuccess(ctx context.Context, user *models.User, clientIP string) error {
+	l.recordAttempt(ctx, user.Username, &user.ID, clientIP, models.LoginAttemptReasonSuccess)
+
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return nil
+	}
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	return l.userRepo.Update(ctx, user)
+}
+
+func (l *loginLockout) recordAttempt(ctx context.Context, username string, userID *uint, clientIP, reason string) {
+	attempt := &models.LoginAttempt{
+		Username:  username,
+		UserID:    userID,
+		IPAddress: clientIP,
+		Success:   reason == models.LoginAttemptReasonSuccess,
+		Reason:    reason,
+	}
+	if err := l.attemptRepo.Create(ctx, attempt); err != nil {
+		logging.FromContext(ctx).Error("failed to record login attempt", "error", err)
+	}
+}
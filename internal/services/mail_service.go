@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+)
+
+type MailMessage struct {
+	To       []string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// MailSender delivers outgoing emails. SMTP is used in deployed environments,
+// the file sender keeps local development free of any mail server.
+type MailSender interface {
+	Send(ctx context.Context, msg *MailMessage) error
+}
+
+func NewMailSender(cfg *config.Config) (MailSender, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required when MAIL_DRIVER is smtp")
+		}
+		return NewSMTPMailSender(cfg), nil
+	case "file", "":
+		return NewFileMailSender(cfg.MailFileDir, cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.MailDriver)
+	}
+}
+
+type smtpMailSender struct {
+	host    string
+	addr    string
+	auth    smtp.Auth
+	from    string
+	timeout time.Duration
+}
+
+func NewSMTPMailSender(cfg *config.Config) MailSender {
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	return &smtpMailSender{
+		host:    cfg.SMTPHost,
+		addr:    net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		auth:    auth,
+		from:    cfg.MailFrom,
+		timeout: cfg.SMTPTimeout,
+	}
+}
+
+// Send follows smtp.SendMail, but dials with ctx and bounds the whole
+// conversation by SMTP_TIMEOUT so a stalled server cannot hold the request.
+func (s *smtpMailSender) Send(ctx context.Context, msg *MailMessage) error {
+	body, err := buildMailBody(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	// note : closing the connection unblocks any pending read or write once
+	// the request is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(body); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+type fileMailSender struct {
+	dir  string
+	from string
+}
+
+func NewFileMailSender(dir, from string) MailSender {
+	return &fileMailSender{dir: dir, from: from}
+}
+
+func (s *fileMailSender) Send(ctx context.Context, msg *MailMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	body, err := buildMailBody(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		return err
+	}
+
+	slog.Info("mail written", "subject", msg.Subject, "recipient", strings.Join(msg.To, ", "), "path", path)
+	return nil
+}
+
+func buildMailBody(from string, msg *MailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTMLBody == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		buf.WriteString(msg.TextBody)
+		return buf.Bytes(), nil
+	}
+
+	writer := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.TextBody},
+		{"text/html; charset=UTF-8", msg.HTMLBody},
+	}
+	for _, p := range parts {
+		part, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {p.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(p.body)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/metrics"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/midtrans/midtrans-go/snap"
+)
+
+const paymentTypeSnap = "snap"
+
+type instrumentedMidtransService struct {
+	next    MidtransService
+	metrics *metrics.Metrics
+}
+
+// NewInstrumentedMidtransService records the latency and failures of every
+// Midtrans call made through next.
+func NewInstrumentedMidtransService(next MidtransService, m *metrics.Metrics) MidtransService {
+	return &instrumentedMidtransService{next, m}
+}
+
+func (s *instrumentedMidtransService) CreateTransaction(ctx context.Context, orderID string, grossAmount int64, items []midtrans.ItemDetails, customer midtrans.CustomerDetails) (*snap.Response, *midtrans.Error) {
+	start := time.Now()
+	resp, err := s.next.CreateTransaction(ctx, orderID, grossAmount, items, customer)
+	s.metrics.ObserveMidtransCall("create_transaction", time.Since(start), err != nil)
+	return resp, err
+}
+
+func (s *instrumentedMidtransService) GetTransactionStatus(ctx context.Context, orderID string) (*coreapi.TransactionStatusResponse, error) {
+	start := time.Now()
+	resp, err := s.next.GetTransactionStatus(ctx, orderID)
+	s.metrics.ObserveMidtransCall("get_transaction_status", time.Since(start), err != nil)
+	return resp, err
+}
+
+func (s *instrumentedMidtransService) CreateQrisTransaction(ctx context.Context, orderID string, amount int64, items []midtrans.ItemDetails, user *models.User) (*coreapi.ChargeResponse, *midtrans.Error) {
+	start := time.Now()
+	resp, err := s.next.CreateQrisTransaction(ctx, orderID, amount, items, user)
+	s.metrics.ObserveMidtransCall("create_qris_transaction", time.Since(start), err != nil)
+	return resp, err
+}
+
+func (s *instrumentedMidtransService) Ping(ctx context.Context) error {
+	return s.next.Ping(ctx)
+}
+
+// instrumentedPaymentService counts payment creations and notifications. The
+// remaining methods go straight to the embedded service.
+type instrumentedPaymentService struct {
+	PaymentService
+	metrics *metrics.Metrics
+}
+
+func NewInstrumentedPaymentService(next PaymentService, m *metrics.Metrics) PaymentService {
+	return &instrumentedPaymentService{next, m}
+}
+
+func (s *instrumentedPaymentService) CreatePayment(ctx context.Context, req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error) {
+	resp, err := s.PaymentService.CreatePayment(ctx, req, user)
+	s.metrics.ObservePaymentCreated(paymentTypeSnap, paymentOutcome(err))
+	return resp, err
+}
+
+func (s *instrumentedPaymentService) CreateQrisPayment(ctx context.Context, req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error) {
+	resp, err := s.PaymentService.CreateQrisPayment(ctx, req, user)
+	s.metrics.ObservePaymentCreated(paymentTypeQris, paymentOutcome(err))
+	return resp, err
+}
+
+func (s *instrumentedPaymentService) HandleNotification(ctx context.Context, notificationPayload map[string]interface{}) error {
+	err := s.PaymentService.HandleNotification(ctx, notificationPayload)
+	transactionStatus, _ := notificationPayload["transaction_status"].(string)
+	s.metrics.ObserveNotification(transactionStatus, !errors.Is(err, ErrInvalidSignature))
+	return err
+}
+
+func paymentOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, ErrEmailNotVerified):
+		return metrics.OutcomeRejected
+	default:
+		return metrics.OutcomeError
+	}
+}
@@ -1,22 +1,34 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
 	"github.com/bagussubagja/backend-payment-gateway-go/config"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	"github.com/midtrans/midtrans-go"
 	"github.com/midtrans/midtrans-go/coreapi"
 	"github.com/midtrans/midtrans-go/snap"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type MidtransService interface {
-	CreateTransaction(orderID string, grossAmount int64, items []midtrans.ItemDetails, customer midtrans.CustomerDetails) (*snap.Response, *midtrans.Error)
-	GetTransactionStatus(orderID string) (*coreapi.TransactionStatusResponse, error)
-	CreateQrisTransaction(orderID string, amount int64, items []midtrans.ItemDetails, user *models.User) (*coreapi.ChargeResponse, *midtrans.Error)
+	CreateTransaction(ctx context.Context, orderID string, grossAmount int64, items []midtrans.ItemDetails, customer midtrans.CustomerDetails) (*snap.Response, *midtrans.Error)
+	GetTransactionStatus(ctx context.Context, orderID string) (*coreapi.TransactionStatusResponse, error)
+	CreateQrisTransaction(ctx context.Context, orderID string, amount int64, items []midtrans.ItemDetails, user *models.User) (*coreapi.ChargeResponse, *midtrans.Error)
+	Ping(ctx context.Context) error
 }
 
 type midtransService struct {
-	snapApi snap.Client
-	coreApi coreapi.Client
+	snapApi    snap.Client
+	coreApi    coreapi.Client
+	httpClient *midtrans.HttpClientImplementation
+	timeout    time.Duration
 }
 
 func NewMidtransService(cfg *config.Config) MidtransService {
@@ -26,13 +38,41 @@ func NewMidtransService(cfg *config.Config) MidtransService {
 	var coreClient coreapi.Client
 	(&coreClient).New(cfg.MidtransServerKey, cfg.MidtransEnvironment)
 
+	// note : the SDK has fixed hosts per environment, so requests for another
+	// base URL such as the local simulator are redirected at the transport
+	var transport http.RoundTripper = http.DefaultTransport
+	if cfg.MidtransBaseURL != "" {
+		target, _ := url.Parse(cfg.MidtransBaseURL)
+		transport = &baseURLTransport{target: target}
+	}
+
 	return &midtransService{
 		snapApi: snapClient,
 		coreApi: coreClient,
+		httpClient: &midtrans.HttpClientImplementation{
+			HttpClient: &http.Client{Transport: otelhttp.NewTransport(transport)},
+			Logger:     midtrans.GetDefaultLogger(cfg.MidtransEnvironment),
+		},
+		timeout: cfg.MidtransTimeout,
 	}
 }
 
-func (s *midtransService) CreateTransaction(orderID string, grossAmount int64, items []midtrans.ItemDetails, customer midtrans.CustomerDetails) (*snap.Response, *midtrans.Error) {
+// snap and core return copies of the SDK clients whose requests carry ctx, so
+// they are traced as part of the caller and cancelled with it. Each call is
+// also bounded by MIDTRANS_TIMEOUT.
+func (s *midtransService) snap(ctx context.Context) snap.Client {
+	client := s.snapApi
+	client.HttpClient = &contextHTTPClient{ctx: ctx, client: s.httpClient, timeout: s.timeout}
+	return client
+}
+
+func (s *midtransService) core(ctx context.Context) coreapi.Client {
+	client := s.coreApi
+	client.HttpClient = &contextHTTPClient{ctx: ctx, client: s.httpClient, timeout: s.timeout}
+	return client
+}
+
+func (s *midtransService) CreateTransaction(ctx context.Context, orderID string, grossAmount int64, items []midtrans.ItemDetails, customer midtrans.CustomerDetails) (*snap.Response, *midtrans.Error) {
 	snapReq := &snap.Request{
 		TransactionDetails: midtrans.TransactionDetails{
 			OrderID:  orderID,
@@ -42,14 +82,20 @@ func (s *midtransService) CreateTransaction(orderID string, grossAmount int64, i
 		Items:          &items,
 	}
 
-	return s.snapApi.CreateTransaction(snapReq)
+	return s.snap(ctx).CreateTransaction(snapReq)
 }
 
-func (s *midtransService) GetTransactionStatus(orderID string) (*coreapi.TransactionStatusResponse, error) {
-	return s.coreApi.CheckTransaction(orderID)
+func (s *midtransService) GetTransactionStatus(ctx context.Context, orderID string) (*coreapi.TransactionStatusResponse, error) {
+	// note : returning the *midtrans.Error directly would turn a nil pointer
+	// into a non-nil error
+	resp, err := s.core(ctx).CheckTransaction(orderID)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
-func (s *midtransService) CreateQrisTransaction(orderID string, amount int64, items []midtrans.ItemDetails, user *models.User) (*coreapi.ChargeResponse, *midtrans.Error) {
+func (s *midtransService) CreateQrisTransaction(ctx context.Context, orderID string, amount int64, items []midtrans.ItemDetails, user *models.User) (*coreapi.ChargeResponse, *midtrans.Error) {
 	chargeReq := &coreapi.ChargeReq{
 		PaymentType: coreapi.PaymentTypeQris,
 		TransactionDetails: midtrans.TransactionDetails{
@@ -63,5 +109,78 @@ func (s *midtransService) CreateQrisTransaction(orderID string, amount int64, it
 			Phone: user.PhoneNumber,
 		},
 	}
-	return s.coreApi.ChargeTransaction(chargeReq)
+	return s.core(ctx).ChargeTransaction(chargeReq)
+}
+
+// Ping checks that the Core API can be reached. Any answer below 500 counts,
+// the endpoint does not need credentials.
+func (s *midtransService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.coreApi.Env.BaseUrl()+"/ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("midtrans responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// contextHTTPClient builds the SDK's requests with a context. The SDK accepts
+// one in its options but drops it when building the request.
+type contextHTTPClient struct {
+	ctx     context.Context
+	client  *midtrans.HttpClientImplementation
+	timeout time.Duration
+}
+
+func (c *contextHTTPClient) Call(method string, url string, apiKey *string, options *midtrans.ConfigOptions, body io.Reader, result interface{}) *midtrans.Error {
+	ctx := c.ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return &midtrans.Error{Message: "Error Request creation failed: " + err.Error(), RawError: err}
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("User-Agent", "Midtrans-Go")
+	if options != nil {
+		if options.PaymentIdempotencyKey != nil {
+			req.Header.Add("Idempotency-Key", *options.PaymentIdempotencyKey)
+		}
+		if options.PaymentOverrideNotification != nil {
+			req.Header.Add("X-Override-Notification", *options.PaymentOverrideNotification)
+		}
+		if options.PaymentAppendNotification != nil {
+			req.Header.Add("X-Append-Notification", *options.PaymentAppendNotification)
+		}
+	}
+	if apiKey != nil {
+		req.SetBasicAuth(*apiKey, "")
+	}
+	return c.client.DoRequest(req, result)
+}
+
+type baseURLTransport struct {
+	target *url.URL
+}
+
+func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	req.URL.Path = strings.TrimSuffix(t.target.Path, "/") + req.URL.Path
+	req.Host = ""
+	return http.DefaultTransport.RoundTrip(req)
 }
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+	"gorm.io/gorm"
+)
+
+type NotificationService interface {
+	NotifyPaymentStatus(ctx context.Context, tx *models.Transaction)
+	GetPreferences(ctx context.Context, userID uint) (*models.NotificationPreferencesResponse, error)
+	UpdatePreferences(ctx context.Context, userID uint, req *models.NotificationPreferencesRequest) (*models.NotificationPreferencesResponse, error)
+}
+
+//go:embed templates/notifications
+var notificationTemplates embed.FS
+
+const notificationChannelEmail = "email"
+
+type notificationLabels struct {
+	OrderID       string
+	Amount        string
+	PaymentMethod string
+	ViewHistory   string
+}
+
+var notificationLabelsByLanguage = map[string]notificationLabels{
+	models.NotificationLanguageIndonesian: {"ID Pesanan", "Jumlah", "Metode pembayaran", "Lihat riwayat pembayaran"},
+	models.NotificationLanguageEnglish:    {"Order ID", "Amount", "Payment method", "View payment history"},
+}
+
+type notificationData struct {
+	Language      string
+	MerchantName  string
+	Name          string
+	OrderID       string
+	Amount        string
+	PaymentMethod string
+	HistoryURL    string
+	Labels        notificationLabels
+}
+
+type notificationTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+	mailSender       MailSender
+	cfg              *config.Config
+	templates        map[string]notificationTemplate
+}
+
+func NewNotificationService(notificationRepo repository.NotificationRepository, mailSender MailSender, cfg *config.Config) (NotificationService, error) {
+	templates := make(map[string]notificationTemplate)
+	for language := range notificationLabelsByLanguage {
+		for _, event := range []string{models.NotificationEventPaymentSuccess, models.NotificationEventPaymentFailed, models.NotificationEventPaymentRefunded} {
+			base := fmt.Sprintf("templates/notifications/%s/%s", language, event)
+			text, err := texttemplate.ParseFS(notificationTemplates, base+".txt")
+			if err != nil {
+				return nil, err
+			}
+			html, err := htmltemplate.ParseFS(notificationTemplates, "templates/notifications/layout.html", base+".html")
+			if err != nil {
+				return nil, err
+			}
+			templates[language+"/"+event] = notificationTemplate{text: text, html: html}
+		}
+	}
+
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		mailSender:       mailSender,
+		cfg:              cfg,
+		templates:        templates,
+	}, nil
+}
+
+// NotifyPaymentStatus emails the owner of tx about its current status if the
+// status is one users are notified about and they did not opt out. Errors are
+// logged only, a notification must never fail the webhook.
+func (s *notificationService) NotifyPaymentStatus(ctx context.Context, tx *models.Transaction) {
+	event := notificationEventForStatus(tx.Status)
+	if event == "" || tx.User.ID == 0 || tx.User.Email == "" {
+		return
+	}
+
+	pref, err := s.loadPreference(ctx, tx.UserID)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to load notification preferences", "user_id", tx.UserID, "order_id", tx.ID, "error", err)
+		return
+	}
+	if !preferenceEnabled(pref, event) {
+		return
+	}
+
+	entry := &models.NotificationLog{
+		UserID:        tx.UserID,
+		TransactionID: tx.ID,
+		Event:         event,
+		Channel:       notificationChannelEmail,
+		Recipient:     tx.User.Email,
+	}
+	claimed, err := s.notificationRepo.ClaimLog(ctx, entry)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to log notification", "event", event, "order_id", tx.ID, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	msg, err := s.render(pref.Language, event, tx)
+	if err == nil {
+		err = s.mailSender.Send(ctx, msg)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to send notification", "event", event, "order_id", tx.ID, "error", err)
+		if err := s.notificationRepo.ReleaseLog(ctx, entry); err != nil {
+			logging.FromContext(ctx).Error("failed to release notification log", "notification_log_id", entry.ID, "order_id", tx.ID, "error", err)
+		}
+	}
+}
+
+func (s *notificationService) GetPreferences(ctx context.Context, userID uint) (*models.NotificationPreferencesResponse, error) {
+	pref, err := s.loadPreference(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toNotificationPreferencesResponse(pref), nil
+}
+
+func (s *notificationService) UpdatePreferences(ctx context.Context, userID uint, req *models.NotificationPreferencesRequest) (*models.NotificationPreferencesResponse, error) {
+	pref, err := s.loadPreference(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Language != nil {
+		pref.Language = *req.Language
+	}
+	if req.PaymentSuccess != nil {
+		pref.PaymentSuccess = *req.PaymentSuccess
+	}
+	if req.PaymentFailed != nil {
+		pref.PaymentFailed = *req.PaymentFailed
+	}
+	if req.PaymentRefunded != nil {
+		pref.PaymentRefunded = *req.PaymentRefunded
+	}
+
+	if err := s.notificationRepo.SavePreference(ctx, pref); err != nil {
+		return nil, err
+	}
+	return toNotificationPreferencesResponse(pref), nil
+}
+
+func (s *notificationService) loadPreference(ctx context.Context, userID uint) (*models.NotificationPreference, error) {
+	pref, err := s.notificationRepo.FindPreference(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.NotificationPreference{
+			UserID:          userID,
+			Language:        models.NotificationLanguageIndonesian,
+			PaymentSuccess:  true,
+			PaymentFailed:   true,
+			PaymentRefunded: true,
+		}, nil
+	}
+	return pref, err
+}
+
+func (s *notificationService) render(language, event string, tx *models.Transaction) (*MailMessage, error) {
+	tmpl, ok := s.templates[language+"/"+event]
+	if !ok {
+		language = models.NotificationLanguageIndonesian
+		tmpl = s.templates[language+"/"+event]
+	}
+
+	data := notificationData{
+		Language:      language,
+		MerchantName:  s.cfg.MerchantName,
+		Name:          tx.User.FullName,
+		OrderID:       tx.ID,
+		Amount:        formatRupiah(tx.Amount),
+		PaymentMethod: paymentMethodLabel(tx.PaymentType),
+		HistoryURL:    s.cfg.AppBaseURL + "/history",
+		Labels:        notificationLabelsByLanguage[language],
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, err
+	}
+
+	return &MailMessage{
+		To:       []string{tx.User.Email},
+		Subject:  subject.String(),
+		TextBody: text.String(),
+		HTMLBody: html.String(),
+	}, nil
+}
+
+func notificationEventForStatus(status string) string {
+	switch status {
+	case "success":
+		return models.NotificationEventPaymentSuccess
+	case "failed":
+		return models.NotificationEventPaymentFailed
+	case "refund", "partial_refund":
+		return models.NotificationEventPaymentRefunded
+	default:
+		return ""
+	}
+}
+
+func preferenceEnabled(pref *models.NotificationPreference, event string) bool {
+	switch event {
+	case models.NotificationEventPaymentSuccess:
+		return pref.PaymentSuccess
+	case models.NotificationEventPaymentFailed:
+		return pref.PaymentFailed
+	case models.NotificationEventPaymentRefunded:
+		return pref.PaymentRefunded
+	default:
+		return false
+	}
+}
+
+func toNotificationPreferencesResponse(pref *models.NotificationPreference) *models.NotificationPreferencesResponse {
+	return &models.NotificationPreferencesResponse{
+		Language:        pref.Language,
+		PaymentSuccess:  pref.PaymentSuccess,
+		PaymentFailed:   pref.PaymentFailed,
+		PaymentRefunded: pref.PaymentRefunded,
+	}
+}
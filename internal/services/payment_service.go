@@ -1,34 +1,74 @@
 package services
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/background"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
 	"github.com/midtrans/midtrans-go"
 	"gorm.io/gorm"
 )
 
 type PaymentService interface {
-	CreatePayment(req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error)
-	GetPaymentStatus(orderID string) (*models.Transaction, error)
-	HandleNotification(notificationPayload map[string]interface{}) error
-	GetPaymentHistory(userID uint) ([]models.Transaction, error)
-	CreateQrisPayment(req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error)
+	CreatePayment(ctx context.Context, req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error)
+	GetPaymentStatus(ctx context.Context, orderID string) (*models.Transaction, error)
+	HandleNotification(ctx context.Context, notificationPayload map[string]interface{}) error
+	GetPaymentHistory(ctx context.Context, userID uint, query *models.PaymentHistoryQuery) (*models.PaymentHistoryResponse, error)
+	CreateQrisPayment(ctx context.Context, req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error)
+	ReplayNotification(ctx context.Context, orderID string) (*models.Transaction, error)
+	Reconcile(ctx context.Context, since time.Time) (*models.ReconcileResult, error)
 }
 
+var (
+	ErrEmailNotVerified = apperror.Forbidden("EMAIL_NOT_VERIFIED", "email address must be verified before making payments")
+	ErrInvalidCursor    = apperror.Validation("INVALID_CURSOR", "invalid pagination cursor")
+	// ErrTransactionNotFound is reported for unknown order IDs, and by the
+	// receipt for transactions of other users.
+	ErrTransactionNotFound = apperror.NotFound("TRANSACTION_NOT_FOUND", "transaction not found")
+	// ErrTransactionForbidden is reported when a user asks for a transaction
+	// of someone else.
+	ErrTransactionForbidden = apperror.Forbidden("TRANSACTION_FORBIDDEN", "you are not authorized to view this transaction")
+	// ErrInvalidSignature is returned for notifications that were not signed
+	// with our server key.
+	ErrInvalidSignature = apperror.Forbidden("INVALID_SIGNATURE", "invalid notification signature")
+	// ErrPaymentGatewayUnavailable wraps failed Midtrans calls.
+	ErrPaymentGatewayUnavailable = apperror.Unavailable("PAYMENT_GATEWAY_UNAVAILABLE", "payment gateway is unavailable, please try again later")
+)
+
+const (
+	defaultHistoryLimit = 20
+
+	paymentTypeQris = "qris"
+)
+
 type paymentService struct {
 	txRepo      repository.TransactionRepository
 	midtransSvc MidtransService
+	notifier    NotificationService
+	hub         EventHub
+	workers     *background.Group
+	serverKey   string
 }
 
-func NewPaymentService(txRepo repository.TransactionRepository, midtransSvc MidtransService) PaymentService {
-	return &paymentService{txRepo, midtransSvc}
+func NewPaymentService(txRepo repository.TransactionRepository, midtransSvc MidtransService, notifier NotificationService, hub EventHub, workers *background.Group, cfg *config.Config) PaymentService {
+	return &paymentService{txRepo, midtransSvc, notifier, hub, workers, cfg.MidtransServerKey}
 }
 
-func (s *paymentService) CreateQrisPayment(req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error) {
+func (s *paymentService) CreateQrisPayment(ctx context.Context, req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error) {
+	if user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
 	orderID := fmt.Sprintf("QRIS-%d", time.Now().UnixNano())
 
 	var totalAmount int64
@@ -44,23 +84,30 @@ func (s *paymentService) CreateQrisPayment(req *models.CreateQrisPaymentRequest,
 		})
 	}
 
-	midtransResp, midtransErr := s.midtransSvc.CreateQrisTransaction(orderID, totalAmount, midtransItems, user)
+	midtransResp, midtransErr := s.midtransSvc.CreateQrisTransaction(ctx, orderID, totalAmount, midtransItems, user)
 	if midtransErr != nil {
-		return nil, midtransErr
+		return nil, ErrPaymentGatewayUnavailable.Wrap(midtransErr)
 	}
 
-	dbTransactionErr := s.txRepo.GetDB().Transaction(func(tx *gorm.DB) error {
-		newTx := &models.Transaction{
-			ID:                    orderID,
-			UserID:                user.ID,
-			Amount:                totalAmount,
-			Status:                "pending",
-			MidtransTransactionID: midtransResp.TransactionID,
-			PaymentURL:            midtransResp.Actions[0].URL,
-		}
+	newTx := &models.Transaction{
+		ID:                    orderID,
+		UserID:                user.ID,
+		Amount:                totalAmount,
+		Status:                "pending",
+		PaymentType:           paymentTypeQris,
+		MidtransTransactionID: midtransResp.TransactionID,
+		PaymentURL:            midtransResp.Actions[0].URL,
+	}
+
+	// note : Midtrans already knows the order, so it is saved even if the
+	// client has gone away in the meantime
+	dbTransactionErr := s.txRepo.GetDB().WithContext(context.WithoutCancel(ctx)).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(newTx).Error; err != nil {
 			return err
 		}
+		if err := tx.Create(newStatusHistory(orderID, newTx.Status, statusSourceCreate)).Error; err != nil {
+			return err
+		}
 
 		for _, item := range req.Items {
 			txItem := models.TransactionItem{
@@ -78,11 +125,12 @@ func (s *paymentService) CreateQrisPayment(req *models.CreateQrisPaymentRequest,
 	})
 
 	if dbTransactionErr != nil {
-		log.Printf("ERROR: Gagal menyimpan transaksi QRIS ke DB: %v", dbTransactionErr)
+		logging.FromContext(ctx).Error("failed to save qris transaction", "order_id", orderID, "user_id", user.ID, "error", dbTransactionErr)
 		return nil, dbTransactionErr
 	}
 
-	log.Printf("SUKSES: Transaksi QRIS dengan Order ID: %s berhasil disimpan ke DB.", orderID)
+	logging.FromContext(ctx).Info("qris transaction created", "order_id", orderID, "user_id", user.ID, "amount", newTx.Amount)
+	s.hub.Publish(models.NewPaymentEvent(models.PaymentEventCreated, newTx))
 
 	return &models.CreateQrisPaymentResponse{
 		OrderID:    orderID,
@@ -91,7 +139,10 @@ func (s *paymentService) CreateQrisPayment(req *models.CreateQrisPaymentRequest,
 	}, nil
 }
 
-func (s *paymentService) CreatePayment(req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error) {
+func (s *paymentService) CreatePayment(ctx context.Context, req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error) {
+	if user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
 
 	orderID := fmt.Sprintf("ORDER-%d", time.Now().UnixNano())
 
@@ -124,9 +175,9 @@ func (s *paymentService) CreatePayment(req *models.CreatePaymentRequest, user *m
 		},
 	}
 
-	midtransResp, midtransErr := s.midtransSvc.CreateTransaction(orderID, totalAmount, midtransItems, customer)
+	midtransResp, midtransErr := s.midtransSvc.CreateTransaction(ctx, orderID, totalAmount, midtransItems, customer)
 	if midtransErr != nil {
-		return nil, midtransErr
+		return nil, ErrPaymentGatewayUnavailable.Wrap(midtransErr)
 	}
 
 	newTx := &models.Transaction{
@@ -149,20 +200,27 @@ func (s *paymentService) CreatePayment(req *models.CreatePaymentRequest, user *m
 		})
 	}
 
-	dbTransactionErr := s.txRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+	// note : Midtrans already knows the order, so it is saved even if the
+	// client has gone away in the meantime
+	dbTransactionErr := s.txRepo.GetDB().WithContext(context.WithoutCancel(ctx)).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&newTx).Error; err != nil {
 			return err
 		}
 		if err := tx.Create(&txItems).Error; err != nil {
 			return err
 		}
+		if err := tx.Create(newStatusHistory(orderID, newTx.Status, statusSourceCreate)).Error; err != nil {
+			return err
+		}
 		return nil
 	})
 
 	if dbTransactionErr != nil {
-		log.Printf("Failed save to DB: %v", dbTransactionErr)
+		logging.FromContext(ctx).Error("failed to save transaction", "order_id", orderID, "user_id", user.ID, "error", dbTransactionErr)
 		return nil, dbTransactionErr
 	}
+	logging.FromContext(ctx).Info("transaction created", "order_id", orderID, "user_id", user.ID, "amount", newTx.Amount)
+	s.hub.Publish(models.NewPaymentEvent(models.PaymentEventCreated, newTx))
 
 	return &models.CreatePaymentResponse{
 		OrderID:       orderID,
@@ -171,22 +229,222 @@ func (s *paymentService) CreatePayment(req *models.CreatePaymentRequest, user *m
 	}, nil
 }
 
-func (s *paymentService) GetPaymentStatus(orderID string) (*models.Transaction, error) {
-	return s.txRepo.FindByID(orderID)
+func (s *paymentService) GetPaymentStatus(ctx context.Context, orderID string) (*models.Transaction, error) {
+	tx, err := s.txRepo.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, translateNotFound(err, ErrTransactionNotFound)
+	}
+	return tx, nil
+}
+
+func (s *paymentService) GetPaymentHistory(ctx context.Context, userID uint, query *models.PaymentHistoryQuery) (*models.PaymentHistoryResponse, error) {
+	filter, err := buildTransactionFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	filter.UserID = &userID
+
+	total, err := s.txRepo.CountByFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// note : fetch one extra row to know whether another page exists
+	pageSize := filter.Limit
+	filter.Limit++
+	transactions, err := s.txRepo.FindByFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.PaymentHistoryResponse{
+		Data:       make([]models.TransactionResponse, 0, pageSize),
+		TotalCount: total,
+		Limit:      pageSize,
+	}
+	if len(transactions) > pageSize {
+		transactions = transactions[:pageSize]
+		resp.NextCursor = encodeCursor(transactions[pageSize-1])
+	}
+	for _, tx := range transactions {
+		resp.Data = append(resp.Data, toTransactionResponse(tx))
+	}
+	return resp, nil
+}
+
+func buildTransactionFilter(query *models.PaymentHistoryQuery) (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{
+		Statuses:    query.Status,
+		MinAmount:   query.MinAmount,
+		MaxAmount:   query.MaxAmount,
+		PaymentType: query.PaymentType,
+		Sort:        query.Sort,
+		Limit:       query.Limit,
+	}
+	if filter.Sort == "" {
+		filter.Sort = models.HistorySortCreatedAtDesc
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+	if !query.From.IsZero() {
+		from := query.From
+		filter.From = &from
+	}
+	if !query.To.IsZero() {
+		// note : "to" is an inclusive date, so match everything before the next day
+		to := query.To.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = cursor
+	}
+	return filter, nil
+}
+
+func encodeCursor(tx models.Transaction) string {
+	raw, _ := json.Marshal(models.TransactionCursor{CreatedAt: tx.CreatedAt, Amount: tx.Amount, ID: tx.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (*models.TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor models.TransactionCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+func toTransactionResponse(tx models.Transaction) models.TransactionResponse {
+	items := make([]models.TransactionItemResponse, 0, len(tx.Items))
+	for _, item := range tx.Items {
+		items = append(items, models.TransactionItemResponse{
+			ItemID:   item.ItemID,
+			Name:     item.Name,
+			Price:    item.Price,
+			Quantity: item.Quantity,
+		})
+	}
+	return models.TransactionResponse{
+		ID:          tx.ID,
+		Amount:      tx.Amount,
+		Status:      tx.Status,
+		PaymentType: tx.PaymentType,
+		PaymentURL:  tx.PaymentURL,
+		CreatedAt:   tx.CreatedAt,
+		UpdatedAt:   tx.UpdatedAt,
+		Items:       items,
+	}
+}
+
+func (s *paymentService) HandleNotification(ctx context.Context, payload map[string]interface{}) error {
+	orderID, _ := payload["order_id"].(string)
+	statusCode, _ := payload["status_code"].(string)
+	grossAmount, _ := payload["gross_amount"].(string)
+	signature, _ := payload["signature_key"].(string)
+
+	expected := utils.MidtransSignature(orderID, statusCode, grossAmount, s.serverKey)
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		return ErrInvalidSignature
+	}
+
+	_, err := s.applyStatusUpdate(ctx, payload, statusSourceNotification)
+	return err
 }
 
-func (s *paymentService) GetPaymentHistory(userID uint) ([]models.Transaction, error) {
-	return s.txRepo.FindByUserID(userID)
+// ReplayNotification fetches the current status of a transaction from
+// Midtrans and runs it through the same path as a webhook notification.
+func (s *paymentService) ReplayNotification(ctx context.Context, orderID string) (*models.Transaction, error) {
+	return s.syncStatus(ctx, orderID, statusSourceReplay)
 }
 
-func (s *paymentService) HandleNotification(payload map[string]interface{}) error {
+// reconcileBatchSize is the number of open transactions loaded per query
+// while reconciling.
+const reconcileBatchSize = 100
+
+// Reconcile asks Midtrans for the status of every transaction created since
+// the given time that is still pending or challenged, which covers webhooks
+// that never reached us.
+func (s *paymentService) Reconcile(ctx context.Context, since time.Time) (*models.ReconcileResult, error) {
+	result := &models.ReconcileResult{}
+	filter := &models.TransactionFilter{
+		Statuses: []string{"pending", "challenge"},
+		From:     &since,
+		Sort:     models.HistorySortCreatedAtAsc,
+		Limit:    reconcileBatchSize,
+	}
+
+	for {
+		transactions, err := s.txRepo.FindByFilter(ctx, filter)
+		if err != nil {
+			return result, err
+		}
+
+		for _, tx := range transactions {
+			result.Checked++
+			updated, err := s.syncStatus(ctx, tx.ID, statusSourceReconcile)
+			if err != nil {
+				result.Failed = append(result.Failed, models.ReconcileFailure{OrderID: tx.ID, Error: err.Error()})
+				continue
+			}
+			if updated.Status != tx.Status {
+				result.Updated++
+			}
+		}
+
+		if len(transactions) < reconcileBatchSize {
+			return result, nil
+		}
+		last := transactions[len(transactions)-1]
+		filter.After = &models.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+func (s *paymentService) syncStatus(ctx context.Context, orderID, source string) (*models.Transaction, error) {
+	status, err := s.midtransSvc.GetTransactionStatus(ctx, orderID)
+	if err != nil {
+		return nil, ErrPaymentGatewayUnavailable.Wrap(err)
+	}
+
+	// note : the status response carries the same fields as a notification
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	payload["order_id"] = orderID
+
+	return s.applyStatusUpdate(ctx, payload, source)
+}
+
+func (s *paymentService) applyStatusUpdate(ctx context.Context, payload map[string]interface{}, source string) (*models.Transaction, error) {
 	orderID, _ := payload["order_id"].(string)
 	transactionStatus, _ := payload["transaction_status"].(string)
 	fraudStatus, _ := payload["fraud_status"].(string)
+	paymentType, _ := payload["payment_type"].(string)
 
-	tx, err := s.txRepo.FindByID(orderID)
+	tx, err := s.txRepo.FindByID(ctx, orderID)
 	if err != nil {
-		return fmt.Errorf("transaction not found: %s", orderID)
+		return nil, translateNotFound(err, ErrTransactionNotFound)
+	}
+	previousStatus := tx.Status
+	if paymentType != "" {
+		tx.PaymentType = paymentType
 	}
 
 	if transactionStatus == "capture" {
@@ -203,5 +461,57 @@ func (s *paymentService) HandleNotification(payload map[string]interface{}) erro
 		tx.Status = transactionStatus
 	}
 
-	return s.txRepo.Update(tx)
+	if tx.Status == "success" && tx.SettledAt == nil {
+		settledAt := parseSettlementTime(payload)
+		tx.SettledAt = &settledAt
+	}
+
+	if err := s.txRepo.Update(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if tx.Status == previousStatus {
+		return tx, nil
+	}
+	if err := s.txRepo.AddStatusHistory(ctx, newStatusHistory(tx.ID, tx.Status, source)); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("transaction status changed", "order_id", tx.ID, "user_id", tx.UserID, "from", previousStatus, "to", tx.Status, "source", source)
+
+	s.hub.Publish(models.NewPaymentEvent(models.PaymentEventUpdated, tx))
+
+	// note : sent in the background so a slow mail server cannot make Midtrans
+	// time out and retry the webhook
+	notifyCtx := context.WithoutCancel(ctx)
+	s.workers.Go(func() { s.notifier.NotifyPaymentStatus(notifyCtx, tx) })
+	return tx, nil
+}
+
+// midtransTimeLayout is the format of the time fields in Midtrans
+// notifications, which are given in WIB (UTC+7).
+const midtransTimeLayout = "2006-01-02 15:04:05"
+
+var midtransLocation = time.FixedZone("WIB", 7*60*60)
+
+func parseSettlementTime(payload map[string]interface{}) time.Time {
+	value, _ := payload["settlement_time"].(string)
+	if t, err := time.ParseInLocation(midtransTimeLayout, value, midtransLocation); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+const (
+	statusSourceCreate       = "create"
+	statusSourceNotification = "notification"
+	statusSourceReconcile    = "reconcile"
+	statusSourceReplay       = "replay"
+)
+
+func newStatusHistory(orderID, status, source string) *models.TransactionStatusHistory {
+	return &models.TransactionStatusHistory{
+		TransactionID: orderID,
+		Status:        status,
+		Source:        source,
+	}
 }
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+)
+
+type ReceiptService interface {
+	GenerateReceipt(ctx context.Context, userID uint, orderID string) ([]byte, error)
+}
+
+var ErrReceiptUnavailable = apperror.Conflict("RECEIPT_UNAVAILABLE", "a receipt is only available for successful transactions")
+
+type receiptService struct {
+	txRepo repository.TransactionRepository
+	cfg    *config.Config
+}
+
+func NewReceiptService(txRepo repository.TransactionRepository, cfg *config.Config) ReceiptService {
+	return &receiptService{txRepo: txRepo, cfg: cfg}
+}
+
+// GenerateReceipt renders the PDF receipt of a successful transaction. A
+// transaction of another user is reported as not found so order IDs cannot
+// be probed.
+func (s *receiptService) GenerateReceipt(ctx context.Context, userID uint, orderID string) ([]byte, error) {
+	tx, err := s.txRepo.FindByID(ctx, orderID)
+	if err != nil || tx.UserID != userID {
+		return nil, ErrTransactionNotFound
+	}
+	if tx.Status != "success" {
+		return nil, ErrReceiptUnavailable
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.render(tx).WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const (
+	receiptMargin     = 50.0
+	receiptLineHeight = 16.0
+	receiptBottom     = utils.PDFPageHeight - 60
+)
+
+func (s *receiptService) render(tx *models.Transaction) *utils.PDFDocument {
+	doc := utils.NewPDFDocument()
+	right := utils.PDFPageWidth - receiptMargin
+	settledAt := receiptSettledAt(tx).In(midtransLocation)
+
+	y := 70.0
+	doc.Text(receiptMargin, y, utils.PDFFontBold, 18, s.cfg.MerchantName)
+	for _, line := range []string{s.cfg.MerchantAddress, s.cfg.MerchantEmail, s.cfg.MerchantPhone} {
+		if line == "" {
+			continue
+		}
+		y += 14
+		doc.Text(receiptMargin, y, utils.PDFFontRegular, 10, line)
+	}
+
+	y += 36
+	doc.Text(receiptMargin, y, utils.PDFFontBold, 14, "PAYMENT RECEIPT")
+	y += 8
+	doc.Line(receiptMargin, y, right, y)
+
+	details := [][2]string{
+		{"Receipt No.", receiptNumber(tx, settledAt)},
+		{"Order ID", tx.ID},
+		{"Billed To", tx.User.FullName},
+		{"Email", tx.User.Email},
+		{"Payment Method", paymentMethodLabel(tx.PaymentType)},
+		{"Settled At", settledAt.Format("02 Jan 2006 15:04:05") + " WIB"},
+	}
+	y += 10
+	for _, detail := range details {
+		y += receiptLineHeight
+		doc.Text(receiptMargin, y, utils.PDFFontRegular, 10, detail[0])
+		doc.Text(receiptMargin+110, y, utils.PDFFontRegular, 10, detail[1])
+	}
+
+	header := func() {
+		doc.Text(receiptMargin, y, utils.PDFFontBold, 10, "Item")
+		doc.Text(330, y, utils.PDFFontBold, 10, "Qty")
+		doc.Text(380, y, utils.PDFFontBold, 10, "Price")
+		doc.Text(480, y, utils.PDFFontBold, 10, "Subtotal")
+		y += 6
+		doc.Line(receiptMargin, y, right, y)
+	}
+
+	y += 32
+	header()
+	for _, item := range tx.Items {
+		y += receiptLineHeight
+		if y > receiptBottom {
+			doc.AddPage()
+			y = 70
+			header()
+			y += receiptLineHeight
+		}
+		doc.Text(receiptMargin, y, utils.PDFFontRegular, 10, truncateText(item.Name, 48))
+		doc.TextRight(360, y, 10, strconv.Itoa(int(item.Quantity)))
+		doc.TextRight(460, y, 10, formatRupiah(item.Price))
+		doc.TextRight(right, y, 10, formatRupiah(item.Price*int64(item.Quantity)))
+	}
+
+	if y+60 > receiptBottom {
+		doc.AddPage()
+		y = 70
+	}
+	y += 10
+	doc.Line(receiptMargin, y, right, y)
+	y += 20
+	doc.Text(380, y, utils.PDFFontBold, 12, "Total")
+	doc.TextRight(right, y, 12, formatRupiah(tx.Amount))
+	y += 20
+	doc.Text(380, y, utils.PDFFontRegular, 10, "Status")
+	doc.Text(480, y, utils.PDFFontBold, 10, "PAID")
+
+	doc.Text(receiptMargin, utils.PDFPageHeight-40, utils.PDFFontRegular, 8, "This receipt was generated electronically and is valid without a signature.")
+	return doc
+}
+
+// receiptSettledAt falls back to the last update for transactions settled
+// before the settlement time was recorded.
+func receiptSettledAt(tx *models.Transaction) time.Time {
+	if tx.SettledAt != nil {
+		return *tx.SettledAt
+	}
+	return tx.UpdatedAt
+}
+
+func receiptNumber(tx *models.Transaction, settledAt time.Time) string {
+	return fmt.Sprintf("RCPT-%s-%s", settledAt.Format("20060102"), strings.ToUpper(tx.ID))
+}
+
+var paymentMethodLabels = map[string]string{
+	"qris":          "QRIS",
+	"gopay":         "GoPay",
+	"shopeepay":     "ShopeePay",
+	"credit_card":   "Credit Card",
+	"bank_transfer": "Bank Transfer",
+	"echannel":      "Mandiri Bill Payment",
+	"permata":       "Permata Virtual Account",
+	"cstore":        "Convenience Store",
+	"akulaku":       "Akulaku",
+}
+
+func paymentMethodLabel(paymentType string) string {
+	if label, ok := paymentMethodLabels[paymentType]; ok {
+		return label
+	}
+	if paymentType == "" {
+		return "-"
+	}
+	words := strings.Fields(strings.ReplaceAll(paymentType, "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func formatRupiah(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "Rp " + b.String()
+}
+
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/background"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+)
+
+type ReportService interface {
+	GetDailyReport(ctx context.Context, query *models.ReportQuery) (*models.DailyReportResponse, error)
+	GetSummary(ctx context.Context, query *models.ReportQuery) (*models.ReportSummaryResponse, error)
+	MaterializeDay(ctx context.Context, day time.Time) error
+	StartNightlyJob(ctx context.Context)
+}
+
+var ErrInvalidReportRange = apperror.Validation("INVALID_REPORT_RANGE", "invalid report range, 'to' must not be before 'from' and the range is limited to 366 days")
+
+const (
+	reportDateLayout   = "2006-01-02"
+	maxReportRangeDays = 366
+)
+
+// note : refunded transactions were paid first, so they count towards gross
+// and are subtracted again for net. Midtrans does not tell us the refunded
+// amount of a partial refund, the full transaction amount is used.
+var (
+	settledStatuses  = map[string]bool{"success": true, "refund": true, "partial_refund": true}
+	refundedStatuses = map[string]bool{"refund": true, "partial_refund": true}
+)
+
+type reportService struct {
+	reportRepo repository.ReportRepository
+	workers    *background.Group
+	cfg        *config.Config
+	location   *time.Location
+}
+
+func NewReportService(reportRepo repository.ReportRepository, workers *background.Group, cfg *config.Config) (ReportService, error) {
+	location, err := time.LoadLocation(cfg.ReportTimezone)
+	if err != nil {
+		return nil, err
+	}
+	return &reportService{reportRepo: reportRepo, workers: workers, cfg: cfg, location: location}, nil
+}
+
+func (s *reportService) GetDailyReport(ctx context.Context, query *models.ReportQuery) (*models.DailyReportResponse, error) {
+	from, to, err := s.reportRange(query)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := s.loadSummaries(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	type rowKey struct{ date, paymentType string }
+	rows := make(map[rowKey]*models.DailyReportRow)
+	for _, summary := range summaries {
+		key := rowKey{summary.ReportDate, summary.PaymentType}
+		row, ok := rows[key]
+		if !ok {
+			row = &models.DailyReportRow{Date: summary.ReportDate, PaymentType: summary.PaymentType, ByStatus: map[string]int64{}}
+			rows[key] = row
+		}
+		row.ByStatus[summary.Status] += summary.TransactionCount
+		addSummary(&row.RevenueFigures, summary)
+	}
+
+	response := &models.DailyReportResponse{
+		From: from.Format(reportDateLayout),
+		To:   to.Format(reportDateLayout),
+		Rows: make([]models.DailyReportRow, 0, len(rows)),
+	}
+	for _, row := range rows {
+		finalizeFigures(&row.RevenueFigures)
+		response.Rows = append(response.Rows, *row)
+	}
+	sort.Slice(response.Rows, func(i, j int) bool {
+		if response.Rows[i].Date != response.Rows[j].Date {
+			return response.Rows[i].Date < response.Rows[j].Date
+		}
+		return response.Rows[i].PaymentType < response.Rows[j].PaymentType
+	})
+	return response, nil
+}
+
+func (s *reportService) GetSummary(ctx context.Context, query *models.ReportQuery) (*models.ReportSummaryResponse, error) {
+	from, to, err := s.reportRange(query)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := s.loadSummaries(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.ReportSummaryResponse{
+		From: from.Format(reportDateLayout),
+		To:   to.Format(reportDateLayout),
+	}
+	byType := make(map[string]*models.PaymentTypeSummary)
+	for _, summary := range summaries {
+		addSummary(&response.RevenueFigures, summary)
+
+		typeSummary, ok := byType[summary.PaymentType]
+		if !ok {
+			typeSummary = &models.PaymentTypeSummary{PaymentType: summary.PaymentType}
+			byType[summary.PaymentType] = typeSummary
+		}
+		addSummary(&typeSummary.RevenueFigures, summary)
+	}
+	finalizeFigures(&response.RevenueFigures)
+
+	response.ByPaymentType = make([]models.PaymentTypeSummary, 0, len(byType))
+	for _, typeSummary := range byType {
+		finalizeFigures(&typeSummary.RevenueFigures)
+		response.ByPaymentType = append(response.ByPaymentType, *typeSummary)
+	}
+	sort.Slice(response.ByPaymentType, func(i, j int) bool {
+		return response.ByPaymentType[i].PaymentType < response.ByPaymentType[j].PaymentType
+	})
+	return response, nil
+}
+
+// MaterializeDay recomputes the summary rows of the given day in the report
+// timezone and replaces whatever was stored for it before.
+func (s *reportService) MaterializeDay(ctx context.Context, day time.Time) error {
+	start := s.startOfDay(day)
+	summaries, err := s.reportRepo.AggregateTransactions(ctx, start, start.AddDate(0, 0, 1), s.location)
+	if err != nil {
+		return err
+	}
+	return s.reportRepo.ReplaceDailySummaries(ctx, start.Format(reportDateLayout), summaries)
+}
+
+// StartNightlyJob materializes the previous REPORT_RECOMPUTE_DAYS days once a
+// day at REPORT_JOB_HOUR. Older days are recomputed as well because
+// notifications can still change the status of a transaction after midnight.
+// The job stops when ctx is cancelled, after finishing a run in progress.
+func (s *reportService) StartNightlyJob(ctx context.Context) {
+	s.workers.Go(func() {
+		for {
+			timer := time.NewTimer(time.Until(s.nextRun(time.Now())))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				s.runNightly(context.WithoutCancel(ctx))
+			}
+		}
+	})
+}
+
+func (s *reportService) runNightly(ctx context.Context) {
+	today := s.startOfDay(time.Now())
+	for i := 1; i <= s.cfg.ReportRecomputeDays; i++ {
+		day := today.AddDate(0, 0, -i)
+		if err := s.MaterializeDay(ctx, day); err != nil {
+			logging.FromContext(ctx).Error("failed to materialize report", "date", day.Format(reportDateLayout), "error", err)
+		}
+	}
+	logging.FromContext(ctx).Info("daily reports materialized", "days", s.cfg.ReportRecomputeDays)
+}
+
+func (s *reportService) nextRun(now time.Time) time.Time {
+	now = now.In(s.location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.cfg.ReportJobHour, 0, 0, 0, s.location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// loadSummaries reads the materialized rows of the range. Days without rows
+// are aggregated live: today, yesterday before the nightly job ran, and days
+// older than REPORT_RECOMPUTE_DAYS that were never materialized, e.g. from
+// before the reports were deployed.
+func (s *reportService) loadSummaries(ctx context.Context, from, to time.Time) ([]models.DailyTransactionSummary, error) {
+	today := s.startOfDay(time.Now())
+	todayDate := today.Format(reportDateLayout)
+
+	stored, err := s.reportRepo.FindDailySummaries(ctx, from.Format(reportDateLayout), to.Format(reportDateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	materialized := make(map[string]bool)
+	summaries := stored[:0]
+	for _, summary := range stored {
+		// note : today keeps changing, rows left by a manual run are ignored
+		if summary.ReportDate == todayDate {
+			continue
+		}
+		materialized[summary.ReportDate] = true
+		summaries = append(summaries, summary)
+	}
+
+	last := to
+	if today.Before(last) {
+		last = today
+	}
+	var gapStart time.Time
+	for day := from; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if !materialized[day.Format(reportDateLayout)] {
+			if gapStart.IsZero() {
+				gapStart = day
+			}
+			continue
+		}
+		if !gapStart.IsZero() {
+			live, err := s.aggregateDays(ctx, gapStart, day)
+			if err != nil {
+				return nil, err
+			}
+			summaries = append(summaries, live...)
+			gapStart = time.Time{}
+		}
+	}
+	if !gapStart.IsZero() {
+		live, err := s.aggregateDays(ctx, gapStart, last.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, live...)
+	}
+	return summaries, nil
+}
+
+// aggregateDays aggregates the days in [start, end) from the transactions.
+func (s *reportService) aggregateDays(ctx context.Context, start, end time.Time) ([]models.DailyTransactionSummary, error) {
+	return s.reportRepo.AggregateTransactions(ctx, start, end, s.location)
+}
+
+func (s *reportService) reportRange(query *models.ReportQuery) (time.Time, time.Time, error) {
+	from := time.Date(query.From.Year(), query.From.Month(), query.From.Day(), 0, 0, 0, 0, s.location)
+	to := time.Date(query.To.Year(), query.To.Month(), query.To.Day(), 0, 0, 0, 0, s.location)
+	if to.Before(from) || to.Sub(from) > maxReportRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, ErrInvalidReportRange
+	}
+	return from, to, nil
+}
+
+func (s *reportService) startOfDay(t time.Time) time.Time {
+	t = t.In(s.location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.location)
+}
+
+func addSummary(figures *models.RevenueFigures, summary models.DailyTransactionSummary) {
+	figures.TransactionCount += summary.TransactionCount
+	if settledStatuses[summary.Status] {
+		figures.SuccessCount += summary.TransactionCount
+		figures.GrossAmount += summary.TotalAmount
+	}
+	if refundedStatuses[summary.Status] {
+		figures.RefundedAmount += summary.TotalAmount
+	}
+}
+
+func finalizeFigures(figures *models.RevenueFigures) {
+	figures.NetAmount = figures.GrossAmount - figures.RefundedAmount
+	if figures.TransactionCount > 0 {
+		figures.SuccessRate = float64(figures.SuccessCount) / float64(figures.TransactionCount)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/tracing"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracedPaymentService struct {
+	next PaymentService
+}
+
+// NewTracedPaymentService opens a span around every method of next, so the
+// queries and Midtrans calls it makes show up beneath it.
+func NewTracedPaymentService(next PaymentService) PaymentService {
+	return &tracedPaymentService{next}
+}
+
+func (s *tracedPaymentService) CreatePayment(ctx context.Context, req *models.CreatePaymentRequest, user *models.User) (*models.CreatePaymentResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.CreatePayment", attribute.Int64("user.id", int64(user.ID)))
+	resp, err := s.next.CreatePayment(ctx, req, user)
+	if resp != nil {
+		span.SetAttributes(attribute.String("order.id", resp.OrderID))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (s *tracedPaymentService) GetPaymentStatus(ctx context.Context, orderID string) (*models.Transaction, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.GetPaymentStatus", attribute.String("order.id", orderID))
+	transaction, err := s.next.GetPaymentStatus(ctx, orderID)
+	tracing.End(span, err)
+	return transaction, err
+}
+
+func (s *tracedPaymentService) HandleNotification(ctx context.Context, notificationPayload map[string]interface{}) error {
+	orderID, _ := notificationPayload["order_id"].(string)
+	transactionStatus, _ := notificationPayload["transaction_status"].(string)
+	ctx, span := tracing.Start(ctx, "PaymentService.HandleNotification",
+		attribute.String("order.id", orderID),
+		attribute.String("payment.transaction_status", transactionStatus),
+	)
+	err := s.next.HandleNotification(ctx, notificationPayload)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedPaymentService) GetPaymentHistory(ctx context.Context, userID uint, query *models.PaymentHistoryQuery) (*models.PaymentHistoryResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.GetPaymentHistory", attribute.Int64("user.id", int64(userID)))
+	resp, err := s.next.GetPaymentHistory(ctx, userID, query)
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (s *tracedPaymentService) CreateQrisPayment(ctx context.Context, req *models.CreateQrisPaymentRequest, user *models.User) (*models.CreateQrisPaymentResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.CreateQrisPayment", attribute.Int64("user.id", int64(user.ID)))
+	resp, err := s.next.CreateQrisPayment(ctx, req, user)
+	if resp != nil {
+		span.SetAttributes(attribute.String("order.id", resp.OrderID))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (s *tracedPaymentService) ReplayNotification(ctx context.Context, orderID string) (*models.Transaction, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.ReplayNotification", attribute.String("order.id", orderID))
+	transaction, err := s.next.ReplayNotification(ctx, orderID)
+	tracing.End(span, err)
+	return transaction, err
+}
+
+func (s *tracedPaymentService) Reconcile(ctx context.Context, since time.Time) (*models.ReconcileResult, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.Reconcile", attribute.String("reconcile.since", since.Format(time.RFC3339)))
+	result, err := s.next.Reconcile(ctx, since)
+	tracing.End(span, err)
+	return result, err
+}
+
+// hashPassword and checkPassword trace bcrypt, which by design takes a large
+// share of registration, login and password changes.
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	hash, err := utils.HashPassword(password)
+	tracing.End(span, err)
+	return hash, err
+}
+
+func checkPassword(ctx context.Context, password, hash string) bool {
+	_, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	defer span.End()
+	return utils.CheckPasswordHash(password, hash)
+}
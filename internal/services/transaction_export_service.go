@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"io"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+)
+
+type TransactionExportService interface {
+	Export(ctx context.Context, w io.Writer, query *models.TransactionExportQuery) error
+}
+
+var transactionExportHeader = []interface{}{
+	"order_id", "user_id", "amount", "status", "payment_type", "created_at", "updated_at",
+	"item_id", "item_name", "item_price", "item_quantity",
+}
+
+type transactionExportService struct {
+	txRepo repository.TransactionRepository
+}
+
+func NewTransactionExportService(txRepo repository.TransactionRepository) TransactionExportService {
+	return &transactionExportService{txRepo}
+}
+
+// Export writes every transaction matching query, one row per item, in the
+// requested format. The caller decides whose transactions are exported by
+// setting query.UserID.
+func (s *transactionExportService) Export(ctx context.Context, w io.Writer, query *models.TransactionExportQuery) error {
+	historyQuery := query.PaymentHistoryQuery
+	historyQuery.Cursor = ""
+	filter, err := buildTransactionFilter(&historyQuery)
+	if err != nil {
+		return err
+	}
+	filter.UserID = query.UserID
+	filter.Limit = 0
+
+	var writer utils.RowWriter
+	if query.Format == models.ExportFormatXLSX {
+		writer, err = utils.NewXLSXRowWriter(w, "Transactions")
+		if err != nil {
+			return err
+		}
+	} else {
+		writer = utils.NewCSVRowWriter(w)
+	}
+
+	if err := writer.WriteRow(transactionExportHeader); err != nil {
+		return err
+	}
+
+	err = s.txRepo.StreamExportRows(ctx, filter, func(row *models.TransactionExportRow) error {
+		return writer.WriteRow([]interface{}{
+			row.TransactionID, row.UserID, row.Amount, row.Status, row.PaymentType, row.CreatedAt, row.UpdatedAt,
+			derefString(row.ItemID), derefString(row.ItemName), derefInt64(row.ItemPrice), derefInt32(row.ItemQuantity),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func derefString(v *string) interface{} {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func derefInt64(v *int64) interface{} {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func derefInt32(v *int32) interface{} {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
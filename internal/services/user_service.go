@@ -1,22 +1,153 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/apperror"
 	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	repositories "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"gorm.io/gorm"
 )
 
 type UserService interface {
-	GetUserByID(id uint) (*models.User, error)
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	UpdateProfile(ctx context.Context, id uint, req *models.UpdateProfileRequest) (*models.User, error)
+	ChangePassword(ctx context.Context, id uint, req *models.ChangePasswordRequest, clientIP string) (*models.ChangePasswordResponse, error)
+	DeleteAccount(ctx context.Context, id uint, req *models.DeleteAccountRequest, clientIP string) error
 }
 
+var (
+	ErrUserNotFound       = apperror.NotFound("USER_NOT_FOUND", "user not found")
+	ErrIncorrectPassword  = apperror.Validation("INCORRECT_PASSWORD", "current password is incorrect")
+	ErrInvalidPhoneNumber = apperror.Validation("INVALID_PHONE_NUMBER", "phone number must contain 8 to 15 digits and may start with +", apperror.FieldError{Field: "phone_number", Message: "must contain 8 to 15 digits and may start with +"})
+)
+
+var phoneNumberPattern = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+
 type userService struct {
+	loginLockout
 	userRepo repositories.UserRepository
+	cfg      *config.Config
+}
+
+func NewUserService(userRepo repositories.UserRepository, attemptRepo repositories.LoginAttemptRepository, cfg *config.Config) UserService {
+	return &userService{
+		loginLockout: loginLockout{userRepo: userRepo, attemptRepo: attemptRepo, cfg: cfg},
+		userRepo:     userRepo,
+		cfg:          cfg,
+	}
+}
+
+func (s *userService) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, translateNotFound(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, id uint, req *models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, translateNotFound(err, ErrUserNotFound)
+	}
+
+	if req.PhoneNumber != nil && !phoneNumberPattern.MatchString(*req.PhoneNumber) {
+		return nil, ErrInvalidPhoneNumber
+	}
+
+	if req.Address != nil {
+		user.Address = *req.Address
+	}
+	if req.PhoneNumber != nil {
+		user.PhoneNumber = *req.PhoneNumber
+	}
+	if req.City != nil {
+		user.City = *req.City
+	}
+	if req.PostalCode != nil {
+		user.PostalCode = *req.PostalCode
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ChangePassword revokes every existing session and returns a fresh token so
+// the caller stays signed in. A wrong current password counts towards the
+// login lockout.
+func (s *userService) ChangePassword(ctx context.Context, id uint, req *models.ChangePasswordRequest, clientIP string) (*models.ChangePasswordResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, translateNotFound(err, ErrUserNotFound)
+	}
+
+	if err := s.verifyPassword(ctx, user, req.CurrentPassword, clientIP); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := hashPassword(ctx, req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = hashedPassword
+	user.TokenVersion++
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(user.ID, user.TokenVersion, s.cfg.JWTSecretKey, s.cfg.JWTExpiration)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ChangePasswordResponse{Token: token}, nil
+}
+
+// DeleteAccount anonymizes the user once the password is confirmed. A wrong
+// password counts towards the login lockout.
+func (s *userService) DeleteAccount(ctx context.Context, id uint, req *models.DeleteAccountRequest, clientIP string) error {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return translateNotFound(err, ErrUserNotFound)
+	}
+
+	if err := s.verifyPassword(ctx, user, req.Password, clientIP); err != nil {
+		return err
+	}
+
+	return s.userRepo.Anonymize(ctx, user)
 }
 
-func NewUserService(userRepo repositories.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+// verifyPassword checks the password of a signed-in user under the same
+// lockout as login, so a stolen session cannot be used to guess it.
+func (s *userService) verifyPassword(ctx context.Context, user *models.User, password, clientIP string) error {
+	if err := s.checkLoginAllowed(ctx, user, clientIP); err != nil {
+		return err
+	}
+	if !checkPassword(ctx, password, user.Password) {
+		if err := s.registerLoginFailure(ctx, user, clientIP, models.LoginAttemptReasonInvalidCredentials); err != nil {
+			return err
+		}
+		return ErrIncorrectPassword
+	}
+	return nil
 }
 
-func (s *userService) GetUserByID(id uint) (*models.User, error) {
-	return s.userRepo.FindByID(id)
+// translateNotFound reports a missing row as notFound, so the client gets a 404
+// instead of a 500.
+func translateNotFound(err error, notFound *apperror.Error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	return err
 }
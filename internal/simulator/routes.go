@@ -0,0 +1,277 @@
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/midtrans/midtrans-go/snap"
+)
+
+func (s *Simulator) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// note : the Snap and Core API hosts both map onto this server
+	mux.HandleFunc("POST /snap/v1/transactions", s.requireServerKey(s.handleSnapCreate))
+	mux.HandleFunc("GET /snap/v4/redirection/{token}", s.handlePaymentPage)
+	mux.HandleFunc("POST /v2/charge", s.requireServerKey(s.handleCharge))
+	mux.HandleFunc("GET /v2/{orderID}/status", s.requireServerKey(s.handleStatus))
+	mux.HandleFunc("POST /v2/{orderID}/cancel", s.requireServerKey(s.handleCancel))
+	mux.HandleFunc("POST /v2/{orderID}/expire", s.requireServerKey(s.handleExpire))
+	mux.HandleFunc("POST /v2/{orderID}/refund", s.requireServerKey(s.handleRefund))
+	mux.HandleFunc("GET /v2/qris/{transactionID}/qr-code", s.handleQRCode)
+	mux.HandleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	// simulator controls, used by the payment page and by scripts
+	mux.HandleFunc("GET /_simulator/transactions", s.handleList)
+	mux.HandleFunc("POST /_simulator/transactions/{orderID}/{action}", s.handleAction)
+	return mux
+}
+
+func (s *Simulator) requireServerKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, _, ok := r.BasicAuth()
+		if !ok || key != s.cfg.ServerKey {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{
+				"status_code":    "401",
+				"status_message": "Unknown Merchant server_key/id",
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Simulator) handleSnapCreate(w http.ResponseWriter, r *http.Request) {
+	var req snap.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TransactionDetails.OrderID == "" || req.TransactionDetails.GrossAmt <= 0 {
+		writeJSON(w, http.StatusBadRequest, snap.Response{ErrorMessages: []string{"transaction_details is invalid"}})
+		return
+	}
+
+	tx, ok := s.create(req.TransactionDetails.OrderID, req.TransactionDetails.GrossAmt, "", r.Header.Get("X-Override-Notification"))
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, snap.Response{ErrorMessages: []string{"transaction_details.order_id has already been taken"}})
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.snapResponse(tx))
+}
+
+func (s *Simulator) handleCharge(w http.ResponseWriter, r *http.Request) {
+	var req coreapi.ChargeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TransactionDetails.OrderID == "" || req.TransactionDetails.GrossAmt <= 0 || req.PaymentType == "" {
+		writeStatus(w, http.StatusBadRequest, "400", "transaction_details or payment_type is invalid")
+		return
+	}
+
+	tx, ok := s.create(req.TransactionDetails.OrderID, req.TransactionDetails.GrossAmt, string(req.PaymentType), r.Header.Get("X-Override-Notification"))
+	if !ok {
+		writeStatus(w, http.StatusOK, "406", "The request could not be completed due to a conflict with the current state of the target resource, please try again")
+		return
+	}
+
+	status := s.statusResponse(tx)
+	resp := &coreapi.ChargeResponse{
+		TransactionID:     tx.TransactionID,
+		OrderID:           tx.OrderID,
+		GrossAmount:       status.GrossAmount,
+		PaymentType:       tx.PaymentType,
+		TransactionTime:   status.TransactionTime,
+		TransactionStatus: tx.Status,
+		StatusCode:        status.StatusCode,
+		StatusMessage:     "Success, transaction is created",
+		Currency:          status.Currency,
+		ExpiryTime:        tx.ExpiryTime.In(wib).Format(midtransTimeLayout),
+	}
+	if tx.PaymentType == string(coreapi.PaymentTypeQris) || tx.PaymentType == string(coreapi.PaymentTypeGopay) {
+		resp.QRString = "SIMULATOR-" + tx.TransactionID
+		resp.Actions = []coreapi.Action{{
+			Name:   "generate-qr-code",
+			Method: http.MethodGet,
+			URL:    fmt.Sprintf("%s/v2/qris/%s/qr-code", s.cfg.BaseURL, tx.TransactionID),
+		}}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Simulator) handleStatus(w http.ResponseWriter, r *http.Request) {
+	tx, ok := s.Transaction(r.PathValue("orderID"))
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "404", "Transaction doesn't exist.")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.statusResponse(tx))
+}
+
+func (s *Simulator) handleCancel(w http.ResponseWriter, r *http.Request) {
+	s.applyAPIChange(w, r.PathValue("orderID"), cancelTransaction, "Success, transaction is canceled")
+}
+
+func (s *Simulator) handleExpire(w http.ResponseWriter, r *http.Request) {
+	s.applyAPIChange(w, r.PathValue("orderID"), func(tx *Transaction) error {
+		if tx.Status != StatusPending {
+			return ErrInvalidTransition
+		}
+		tx.Status = StatusExpire
+		return nil
+	}, "Success, transaction has expired")
+}
+
+func (s *Simulator) handleRefund(w http.ResponseWriter, r *http.Request) {
+	var req coreapi.RefundReq
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeStatus(w, http.StatusBadRequest, "400", "refund request is invalid")
+			return
+		}
+	}
+	s.applyAPIChange(w, r.PathValue("orderID"), refundTransaction(req.Amount), "Success, refund request is approved")
+}
+
+// applyAPIChange serves the Core API calls that change a transaction. Like
+// Midtrans, the notification goes out after the response.
+func (s *Simulator) applyAPIChange(w http.ResponseWriter, orderID string, change func(tx *Transaction) error, message string) {
+	tx, err := s.transition(orderID, change)
+	switch {
+	case errors.Is(err, ErrTransactionNotFound):
+		writeStatus(w, http.StatusNotFound, "404", "Transaction doesn't exist.")
+		return
+	case err != nil:
+		writeStatus(w, http.StatusOK, "412", "Merchant cannot modify the status of the transaction: "+err.Error())
+		return
+	}
+
+	status := s.statusResponse(tx)
+	status.StatusMessage = message
+	writeJSON(w, http.StatusOK, status)
+	go s.notify(tx)
+}
+
+func (s *Simulator) handleQRCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="240" height="240" viewBox="0 0 240 240">`+
+		`<rect width="240" height="240" fill="#fff" stroke="#000" stroke-width="8"/>`+
+		`<text x="120" y="112" font-family="monospace" font-size="20" text-anchor="middle">QRIS</text>`+
+		`<text x="120" y="140" font-family="monospace" font-size="12" text-anchor="middle">%s</text></svg>`,
+		template.HTMLEscapeString(r.PathValue("transactionID")))
+}
+
+type transactionView struct {
+	OrderID       string `json:"order_id"`
+	TransactionID string `json:"transaction_id"`
+	PaymentType   string `json:"payment_type"`
+	Status        string `json:"transaction_status"`
+	FraudStatus   string `json:"fraud_status,omitempty"`
+	GrossAmount   int64  `json:"gross_amount"`
+	RefundAmount  int64  `json:"refund_amount,omitempty"`
+}
+
+func newTransactionView(tx Transaction) transactionView {
+	return transactionView{
+		OrderID:       tx.OrderID,
+		TransactionID: tx.TransactionID,
+		PaymentType:   tx.PaymentType,
+		Status:        tx.Status,
+		FraudStatus:   tx.FraudStatus,
+		GrossAmount:   tx.GrossAmount,
+		RefundAmount:  tx.RefundAmount,
+	}
+}
+
+func (s *Simulator) handleList(w http.ResponseWriter, r *http.Request) {
+	views := []transactionView{}
+	for _, tx := range s.Transactions() {
+		views = append(views, newTransactionView(tx))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// handleAction drives a transaction from outside, e.g.
+// POST /_simulator/transactions/ORDER-1/settle. The notification has been
+// delivered when the response arrives.
+func (s *Simulator) handleAction(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderID")
+	actions := map[string]func(string) error{
+		"settle":    s.Settle,
+		"challenge": s.Challenge,
+		"expire":    s.Expire,
+		"deny":      s.Deny,
+		"cancel":    s.Cancel,
+		"refund":    func(orderID string) error { return s.Refund(orderID, 0) },
+	}
+	action, ok := actions[r.PathValue("action")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown action"})
+		return
+	}
+
+	err := action(orderID)
+	switch {
+	case errors.Is(err, ErrTransactionNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, ErrInvalidTransition):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// note : the payment page posts a plain form, send the browser back to it
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" && r.FormValue("return") != "" {
+		http.Redirect(w, r, r.FormValue("return"), http.StatusSeeOther)
+		return
+	}
+	tx, _ := s.Transaction(orderID)
+	writeJSON(w, http.StatusOK, newTransactionView(tx))
+}
+
+var paymentPage = template.Must(template.New("payment").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Midtrans Simulator</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 3rem auto">
+<h1>Midtrans Simulator</h1>
+<p>Order <strong>{{.OrderID}}</strong>, Rp {{.GrossAmount}}</p>
+<p>Status: <strong>{{.Status}}</strong></p>
+{{range .Actions}}
+<form method="post" action="/_simulator/transactions/{{$.OrderID}}/{{.}}" style="display: inline">
+<input type="hidden" name="return" value="{{$.Return}}">
+<button type="submit">{{.}}</button>
+</form>
+{{end}}
+</body>
+</html>`))
+
+func (s *Simulator) handlePaymentPage(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	for _, tx := range s.Transactions() {
+		if tx.SnapToken != token {
+			continue
+		}
+		paymentPage.Execute(w, map[string]interface{}{
+			"OrderID":     tx.OrderID,
+			"GrossAmount": tx.GrossAmount,
+			"Status":      tx.Status,
+			"Actions":     []string{"settle", "challenge", "deny", "expire"},
+			"Return":      r.URL.Path,
+		})
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeStatus(w http.ResponseWriter, httpStatus int, statusCode, message string) {
+	writeJSON(w, httpStatus, map[string]string{"status_code": statusCode, "status_message": message})
+}
@@ -0,0 +1,354 @@
+// Package simulator is an in-memory stand-in for the Midtrans Snap and Core
+// APIs. Point MIDTRANS_BASE_URL at it to run the payment flow without network
+// access; it sends signed notifications back like Midtrans does.
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/midtrans/midtrans-go/snap"
+)
+
+const (
+	StatusPending       = "pending"
+	StatusCapture       = "capture"
+	StatusSettlement    = "settlement"
+	StatusDeny          = "deny"
+	StatusExpire        = "expire"
+	StatusCancel        = "cancel"
+	StatusRefund        = "refund"
+	StatusPartialRefund = "partial_refund"
+)
+
+const (
+	midtransTimeLayout = "2006-01-02 15:04:05"
+	defaultPaymentType = "bank_transfer"
+	merchantID         = "SIMULATOR"
+	transactionTTL     = 15 * time.Minute
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrInvalidTransition   = errors.New("transaction cannot move to this status")
+)
+
+// note : Midtrans reports its times in WIB
+var wib = time.FixedZone("WIB", 7*60*60)
+
+type Config struct {
+	ServerKey string
+	// NotificationURL receives the payment notifications, like the URL set in
+	// the Midtrans dashboard. Requests can override it per transaction with
+	// the X-Override-Notification header.
+	NotificationURL string
+	// BaseURL is the address the simulator is reachable on, used to build
+	// payment links.
+	BaseURL string
+}
+
+type Transaction struct {
+	OrderID         string
+	TransactionID   string
+	SnapToken       string
+	PaymentType     string
+	Status          string
+	FraudStatus     string
+	GrossAmount     int64
+	RefundAmount    int64
+	TransactionTime time.Time
+	SettlementTime  time.Time
+	ExpiryTime      time.Time
+
+	notificationURL string
+}
+
+type Simulator struct {
+	cfg    Config
+	client *http.Client
+	mux    *http.ServeMux
+
+	mu           sync.Mutex
+	transactions map[string]*Transaction
+	seq          int
+}
+
+func New(cfg Config) *Simulator {
+	s := &Simulator{
+		cfg:          cfg,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		transactions: make(map[string]*Transaction),
+	}
+	s.mux = s.routes()
+	return s
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// URL returns the base URL of the simulator.
+func (s *Simulator) URL() string {
+	return s.cfg.BaseURL
+}
+
+// SetNotificationURL changes where notifications are sent, for when the
+// receiving server starts after the simulator.
+func (s *Simulator) SetNotificationURL(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg.NotificationURL = url
+}
+
+// Transaction returns a copy of the stored transaction.
+func (s *Simulator) Transaction(orderID string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.transactions[orderID]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *tx, true
+}
+
+func (s *Simulator) Transactions() []Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transactions := make([]Transaction, 0, len(s.transactions))
+	for _, tx := range s.transactions {
+		transactions = append(transactions, *tx)
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].TransactionTime.Before(transactions[j].TransactionTime)
+	})
+	return transactions
+}
+
+// Settle completes the payment of a pending transaction and delivers the
+// notification before returning.
+func (s *Simulator) Settle(orderID string) error {
+	return s.transitionAndNotify(orderID, func(tx *Transaction) error {
+		if tx.Status != StatusPending && !(tx.Status == StatusCapture && tx.FraudStatus == "challenge") {
+			return ErrInvalidTransition
+		}
+		if tx.PaymentType == "" {
+			tx.PaymentType = defaultPaymentType
+		}
+		tx.Status = StatusSettlement
+		tx.FraudStatus = "accept"
+		tx.SettlementTime = time.Now()
+		return nil
+	})
+}
+
+// Challenge marks a pending card payment as captured but flagged by the
+// fraud detection.
+func (s *Simulator) Challenge(orderID string) error {
+	return s.transitionAndNotify(orderID, func(tx *Transaction) error {
+		if tx.Status != StatusPending {
+			return ErrInvalidTransition
+		}
+		tx.PaymentType = "credit_card"
+		tx.Status = StatusCapture
+		tx.FraudStatus = "challenge"
+		return nil
+	})
+}
+
+func (s *Simulator) Expire(orderID string) error {
+	return s.transitionAndNotify(orderID, func(tx *Transaction) error {
+		if tx.Status != StatusPending {
+			return ErrInvalidTransition
+		}
+		tx.Status = StatusExpire
+		return nil
+	})
+}
+
+func (s *Simulator) Deny(orderID string) error {
+	return s.transitionAndNotify(orderID, func(tx *Transaction) error {
+		if tx.Status != StatusPending && tx.Status != StatusCapture {
+			return ErrInvalidTransition
+		}
+		if tx.PaymentType == "" {
+			tx.PaymentType = "credit_card"
+		}
+		tx.Status = StatusDeny
+		tx.FraudStatus = "deny"
+		return nil
+	})
+}
+
+func (s *Simulator) Cancel(orderID string) error {
+	return s.transitionAndNotify(orderID, cancelTransaction)
+}
+
+// Refund refunds amount of a settled transaction, or all of it for zero.
+func (s *Simulator) Refund(orderID string, amount int64) error {
+	return s.transitionAndNotify(orderID, refundTransaction(amount))
+}
+
+func cancelTransaction(tx *Transaction) error {
+	if tx.Status != StatusPending && tx.Status != StatusCapture {
+		return ErrInvalidTransition
+	}
+	tx.Status = StatusCancel
+	return nil
+}
+
+func refundTransaction(amount int64) func(tx *Transaction) error {
+	return func(tx *Transaction) error {
+		if tx.Status != StatusSettlement && tx.Status != StatusPartialRefund {
+			return ErrInvalidTransition
+		}
+		if amount <= 0 {
+			amount = tx.GrossAmount - tx.RefundAmount
+		}
+		if tx.RefundAmount+amount > tx.GrossAmount {
+			return fmt.Errorf("%w: refund exceeds the remaining amount", ErrInvalidTransition)
+		}
+		tx.RefundAmount += amount
+		tx.Status = StatusPartialRefund
+		if tx.RefundAmount == tx.GrossAmount {
+			tx.Status = StatusRefund
+		}
+		return nil
+	}
+}
+
+func (s *Simulator) transitionAndNotify(orderID string, change func(tx *Transaction) error) error {
+	tx, err := s.transition(orderID, change)
+	if err != nil {
+		return err
+	}
+	return s.notify(tx)
+}
+
+// transition applies change under the lock and returns a snapshot of the
+// result for the notification.
+func (s *Simulator) transition(orderID string, change func(tx *Transaction) error) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.transactions[orderID]
+	if !ok {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	if err := change(tx); err != nil {
+		return Transaction{}, err
+	}
+	return *tx, nil
+}
+
+// create stores a new pending transaction and returns a snapshot of it. Snap
+// transactions get a token and choose their payment type when paid.
+func (s *Simulator) create(orderID string, grossAmount int64, paymentType, notificationURL string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.transactions[orderID]; exists {
+		return Transaction{}, false
+	}
+	s.seq++
+	now := time.Now()
+	tx := &Transaction{
+		OrderID:         orderID,
+		TransactionID:   fmt.Sprintf("sim-%d-%d", now.Unix(), s.seq),
+		PaymentType:     paymentType,
+		Status:          StatusPending,
+		GrossAmount:     grossAmount,
+		TransactionTime: now,
+		ExpiryTime:      now.Add(transactionTTL),
+		notificationURL: notificationURL,
+	}
+	if paymentType == "" {
+		tx.SnapToken = fmt.Sprintf("snap-%d-%d", now.UnixNano(), s.seq)
+	}
+	s.transactions[orderID] = tx
+	return *tx, true
+}
+
+// notify posts the current state of tx to the notification URL.
+func (s *Simulator) notify(tx Transaction) error {
+	url := tx.notificationURL
+	if url == "" {
+		s.mu.Lock()
+		url = s.cfg.NotificationURL
+		s.mu.Unlock()
+	}
+	if url == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(s.statusResponse(tx))
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("deliver notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("deliver notification: %s responded %s", url, resp.Status)
+	}
+	return nil
+}
+
+// statusResponse renders tx the way the status API and the notifications
+// do, including the signature.
+func (s *Simulator) statusResponse(tx Transaction) *coreapi.TransactionStatusResponse {
+	statusCode := statusCodeOf(tx.Status)
+	grossAmount := formatAmount(tx.GrossAmount)
+	resp := &coreapi.TransactionStatusResponse{
+		TransactionTime:   tx.TransactionTime.In(wib).Format(midtransTimeLayout),
+		GrossAmount:       grossAmount,
+		Currency:          "IDR",
+		OrderID:           tx.OrderID,
+		PaymentType:       tx.PaymentType,
+		SignatureKey:      utils.MidtransSignature(tx.OrderID, statusCode, grossAmount, s.cfg.ServerKey),
+		StatusCode:        statusCode,
+		TransactionID:     tx.TransactionID,
+		TransactionStatus: tx.Status,
+		FraudStatus:       tx.FraudStatus,
+		StatusMessage:     "Success, transaction is found",
+		MerchantID:        merchantID,
+	}
+	if !tx.SettlementTime.IsZero() {
+		resp.SettlementTime = tx.SettlementTime.In(wib).Format(midtransTimeLayout)
+	}
+	if tx.RefundAmount > 0 {
+		resp.RefundAmount = formatAmount(tx.RefundAmount)
+	}
+	return resp
+}
+
+func statusCodeOf(status string) string {
+	switch status {
+	case StatusPending:
+		return "201"
+	case StatusDeny:
+		return "202"
+	case StatusExpire:
+		return "407"
+	default:
+		return "200"
+	}
+}
+
+func formatAmount(amount int64) string {
+	return fmt.Sprintf("%d.00", amount)
+}
+
+func (s *Simulator) snapResponse(tx Transaction) *snap.Response {
+	return &snap.Response{
+		Token:       tx.SnapToken,
+		RedirectURL: fmt.Sprintf("%s/snap/v4/redirection/%s", s.cfg.BaseURL, tx.SnapToken),
+	}
+}
@@ -0,0 +1,17 @@
+package simulator
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// NewTestServer starts a simulator on a local httptest server that is closed
+// when the test ends. Use URL as MIDTRANS_BASE_URL.
+func NewTestServer(tb testing.TB, cfg Config) *Simulator {
+	tb.Helper()
+	sim := New(cfg)
+	server := httptest.NewServer(sim)
+	tb.Cleanup(server.Close)
+	sim.cfg.BaseURL = server.URL
+	return sim
+}
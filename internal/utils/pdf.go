@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PDF page size (A4) in points.
+const (
+	PDFPageWidth  = 595.0
+	PDFPageHeight = 842.0
+)
+
+type PDFFont string
+
+// The standard Type 1 fonts below are built into every PDF reader, so no font
+// files have to be embedded. Courier has a fixed advance of 600/1000 em which
+// makes right-aligned amounts easy to lay out.
+const (
+	PDFFontRegular PDFFont = "F1"
+	PDFFontBold    PDFFont = "F2"
+	PDFFontMono    PDFFont = "F3"
+)
+
+var pdfFonts = []struct {
+	name     PDFFont
+	baseFont string
+}{
+	{PDFFontRegular, "Helvetica"},
+	{PDFFontBold, "Helvetica-Bold"},
+	{PDFFontMono, "Courier"},
+}
+
+// PDFDocument is a minimal PDF writer for simple text documents such as
+// receipts. Coordinates are in points with the origin at the top left corner.
+type PDFDocument struct {
+	pages []*bytes.Buffer
+}
+
+func NewPDFDocument() *PDFDocument {
+	doc := &PDFDocument{}
+	doc.AddPage()
+	return doc
+}
+
+func (d *PDFDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *PDFDocument) current() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func (d *PDFDocument) Text(x, y float64, font PDFFont, size float64, text string) {
+	fmt.Fprintf(d.current(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, PDFPageHeight-y, pdfEscape(text))
+}
+
+// TextRight draws monospaced text so that it ends at x.
+func (d *PDFDocument) TextRight(x, y float64, size float64, text string) {
+	width := float64(len([]rune(text))) * size * 0.6
+	d.Text(x-width, y, PDFFontMono, size, text)
+}
+
+func (d *PDFDocument) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.current(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PDFPageHeight-y1, x2, PDFPageHeight-y2)
+}
+
+func (d *PDFDocument) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+
+	startObject := func() int {
+		offsets = append(offsets, out.Len())
+		id := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n", id)
+		return id
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Object ids are fixed: 1 catalog, 2 page tree, then the fonts, then a
+	// page and content stream pair per page.
+	firstPage := 3 + len(pdfFonts)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*2)
+	}
+
+	startObject()
+	out.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	startObject()
+	fmt.Fprintf(&out, "<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(d.pages))
+
+	var fontRefs strings.Builder
+	for _, font := range pdfFonts {
+		id := startObject()
+		fmt.Fprintf(&out, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\nendobj\n", font.baseFont)
+		fmt.Fprintf(&fontRefs, "/%s %d 0 R ", font.name, id)
+	}
+
+	for _, page := range d.pages {
+		pageID := startObject()
+		fmt.Fprintf(&out, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << %s>> >> /Contents %d 0 R >>\nendobj\n",
+			PDFPageWidth, PDFPageHeight, fontRefs.String(), pageID+1)
+		startObject()
+		fmt.Fprintf(&out, "<< /Length %d >>\nstream\n", page.Len())
+		out.Write(page.Bytes())
+		out.WriteString("endstream\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+// pdfEscape encodes text for a literal string using the Latin-1 subset of
+// WinAnsiEncoding. Characters outside of it are replaced with '?'.
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
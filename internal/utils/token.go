@@ -1,20 +1,38 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenPurposeTwoFactor marks short-lived tokens issued between the password
+// and the TOTP step of a login. They must not be accepted as access tokens.
+const TokenPurposeTwoFactor = "2fa_challenge"
+
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID       uint   `json:"user_id"`
+	TokenVersion uint   `json:"token_version"`
+	Purpose      string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, secretKey string, expiration time.Duration) (string, error) {
+func GenerateToken(userID uint, tokenVersion uint, secretKey string, expiration time.Duration) (string, error) {
+	return generateToken(userID, tokenVersion, "", secretKey, expiration)
+}
+
+func GenerateChallengeToken(userID uint, tokenVersion uint, secretKey string, expiration time.Duration) (string, error) {
+	return generateToken(userID, tokenVersion, TokenPurposeTwoFactor, secretKey, expiration)
+}
+
+func generateToken(userID uint, tokenVersion uint, purpose string, secretKey string, expiration time.Duration) (string, error) {
 	claims := &Claims{
-		UserID: userID,
+		UserID:       userID,
+		TokenVersion: tokenVersion,
+		Purpose:      purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -25,7 +43,7 @@ func GenerateToken(userID uint, secretKey string, expiration time.Duration) (str
 	return token.SignedString([]byte(secretKey))
 }
 
-func ValidateToken(tokenString string, secretKey string) (uint, error) {
+func ParseToken(tokenString string, secretKey string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -34,12 +52,30 @@ func ValidateToken(tokenString string, secretKey string) (uint, error) {
 	})
 
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UserID, nil
+		return claims, nil
+	}
+
+	return nil, fmt.Errorf("invalid token")
+}
+
+func ValidateToken(tokenString string, secretKey string) (uint, error) {
+	claims, err := ParseToken(tokenString, secretKey)
+	if err != nil {
+		return 0, err
 	}
+	return claims.UserID, nil
+}
 
-	return 0, fmt.Errorf("invalid token")
+// GenerateRandomToken returns a hex encoded, cryptographically random token
+// suitable for one-time links sent to users.
+func GenerateRandomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
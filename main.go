@@ -2,40 +2,51 @@ package main
 
 import (
 	"fmt"
-	"log"
-
-	"github.com/bagussubagja/backend-payment-gateway-go/api/routes"
-	"github.com/bagussubagja/backend-payment-gateway-go/config"
-	repository "github.com/bagussubagja/backend-payment-gateway-go/internal/repositories"
-	"github.com/bagussubagja/backend-payment-gateway-go/internal/services"
-	"github.com/bagussubagja/backend-payment-gateway-go/storage"
+	"os"
 )
 
+const usage = `usage: main [command] [arguments]
+
+commands:
+  serve                       start the HTTP server (default)
+  migrate <command>           apply, roll back or inspect database migrations
+  seed                        create demo users and transactions
+  create-admin                create an admin account or promote an existing user
+  reconcile --since <when>    sync open transactions with Midtrans
+  replay-notification <id>    re-apply the current Midtrans status of an order
+  export-transactions         write transactions as CSV or XLSX
+  midtrans-simulator          run a local fake of the Midtrans APIs
+
+Run "main <command> -h" for the flags of a command.`
+
+var commands = map[string]func(args []string){
+	"serve":               runServe,
+	"migrate":             runMigrate,
+	"seed":                runSeed,
+	"create-admin":        runCreateAdmin,
+	"reconcile":           runReconcile,
+	"replay-notification": runReplayNotification,
+	"export-transactions": runExportTransactions,
+	"midtrans-simulator":  runSimulator,
+}
+
 func main() {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("could not load config: %v", err)
+	// note : without a command the binary still starts the server, which keeps
+	// existing deployments running "./main" working
+	if len(os.Args) < 2 {
+		runServe(nil)
+		return
 	}
 
-	db, err := storage.NewPostgresDB(cfg)
-	if err != nil {
-		log.Fatalf("could not connect to db: %v", err)
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Println(usage)
+		return
 	}
-	fmt.Println("Database connected successfully")
-
-	userRepo := repository.NewUserRepository(db)
-	transactionRepo := repository.NewTransactionRepository(db)
-
-	authService := services.NewAuthService(userRepo, cfg)
-	userService := services.NewUserService(userRepo)
-	midtransService := services.NewMidtransService(cfg)
-	paymentService := services.NewPaymentService(transactionRepo, midtransService)
-
-	router := routes.SetupRouter(authService, userService, paymentService, cfg)
-
-	serverAddress := fmt.Sprintf(":%s", cfg.ServerPort)
-	log.Printf("Server is running on port %s", cfg.ServerPort)
-	if err := router.Run(serverAddress); err != nil {
-		log.Fatalf("could not start server: %v", err)
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", name, usage)
+		os.Exit(2)
 	}
+	command(os.Args[2:])
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/storage"
+)
+
+var migrationsDirs = []string{"storage/migrations/postgres", "storage/migrations/sqlite"}
+
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down [steps]   roll back the last applied migration, or the given number of them
+  status         list migrations and when they were applied
+  create <name>  add an empty up/down migration pair for every database driver`
+
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	// note : create only writes files, it needs neither config nor a database
+	if args[0] == "create" {
+		if len(args) < 2 {
+			log.Fatal("migrate create: migration name is required")
+		}
+		paths, err := storage.CreateMigration(args[1], migrationsDirs...)
+		if err != nil {
+			log.Fatalf("could not create migration: %v", err)
+		}
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+		return
+	}
+
+	a, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := newMigrator(a)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("migrate down: invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+		for _, migration := range reverted {
+			fmt.Printf("Rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("migrate status: %v", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/tracing"
+)
+
+func runReconcile(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	sinceValue := flags.String("since", "24h", "check transactions created within this duration (e.g. 72h) or since this date (YYYY-MM-DD)")
+	flags.Parse(args)
+
+	since, err := parseSince(*sinceValue)
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+
+	a, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stopTracing, err := startTracing(a)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopTracing()
+
+	ctx, span := tracing.Start(context.Background(), "reconcile")
+	result, err := a.PaymentService.Reconcile(ctx, since)
+	tracing.End(span, err)
+	if result != nil {
+		for _, failure := range result.Failed {
+			fmt.Printf("Failed %s: %s\n", failure.OrderID, failure.Error)
+		}
+		fmt.Printf("Checked %d open transactions since %s, updated %d, failed %d\n", result.Checked, since.Format(time.RFC3339), result.Updated, len(result.Failed))
+	}
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+	waitForWorkers(a)
+}
+
+func parseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, expected a duration such as 72h or a date such as 2024-01-31", value)
+}
+
+func runReplayNotification(args []string) {
+	flags := flag.NewFlagSet("replay-notification", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("usage: replay-notification <order-id>")
+	}
+	orderID := flags.Arg(0)
+
+	a, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stopTracing, err := startTracing(a)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopTracing()
+
+	ctx, span := tracing.Start(context.Background(), "replay-notification")
+	tx, err := a.PaymentService.ReplayNotification(ctx, orderID)
+	tracing.End(span, err)
+	if err != nil {
+		log.Fatalf("replay-notification: %v", err)
+	}
+	fmt.Printf("Order %s is %s\n", tx.ID, tx.Status)
+	waitForWorkers(a)
+}
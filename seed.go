@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/app"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/utils"
+	"gorm.io/gorm"
+)
+
+type demoProduct struct {
+	ID    string
+	Name  string
+	Price int64
+}
+
+var demoProducts = []demoProduct{
+	{ID: "PRD-001", Name: "Kopi Susu Gula Aren", Price: 18000},
+	{ID: "PRD-002", Name: "Roti Bakar Cokelat", Price: 25000},
+	{ID: "PRD-003", Name: "Nasi Goreng Spesial", Price: 35000},
+	{ID: "PRD-004", Name: "Teh Tarik", Price: 15000},
+	{ID: "PRD-005", Name: "Voucher Pulsa 50K", Price: 51500},
+}
+
+var demoUsers = []models.User{
+	{FullName: "Demo User", Username: "demo", Email: "demo@example.com", City: "Jakarta", PostalCode: "10110", Role: models.RoleUser},
+	{FullName: "Demo Admin", Username: "demo_admin", Email: "demo.admin@example.com", City: "Bandung", PostalCode: "40111", Role: models.RoleAdmin},
+}
+
+type demoLine struct {
+	Product  int
+	Quantity int32
+}
+
+// demoTransactions describes the transactions created for every demo user.
+// Lines refer to demoProducts by index.
+var demoTransactions = []struct {
+	Status      string
+	PaymentType string
+	DaysAgo     int
+	Lines       []demoLine
+}{
+	{Status: "success", PaymentType: "qris", DaysAgo: 6, Lines: []demoLine{{0, 2}, {1, 1}}},
+	{Status: "success", PaymentType: "bank_transfer", DaysAgo: 4, Lines: []demoLine{{4, 1}}},
+	{Status: "failed", PaymentType: "gopay", DaysAgo: 3, Lines: []demoLine{{2, 1}}},
+	{Status: "refund", PaymentType: "qris", DaysAgo: 2, Lines: []demoLine{{3, 3}}},
+	{Status: "pending", PaymentType: "bank_transfer", DaysAgo: 0, Lines: []demoLine{{0, 1}, {2, 2}}},
+}
+
+func runSeed(args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	password := flags.String("password", "demo12345", "password for the demo users")
+	flags.Parse(args)
+
+	a, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hashedPassword, err := utils.HashPassword(*password)
+	if err != nil {
+		log.Fatalf("could not hash password: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, demo := range demoUsers {
+		user, err := a.UserRepo.FindByUsername(ctx, demo.Username)
+		switch {
+		case err == nil:
+			fmt.Printf("User %s already exists\n", user.Username)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			now := time.Now()
+			newUser := demo
+			user = &newUser
+			user.Password = hashedPassword
+			user.EmailVerifiedAt = &now
+			if err := a.UserRepo.Create(ctx, user); err != nil {
+				log.Fatalf("could not create user %s: %v", demo.Username, err)
+			}
+			fmt.Printf("Created user %s (%s)\n", user.Username, user.Role)
+		default:
+			log.Fatalf("could not look up user %s: %v", demo.Username, err)
+		}
+
+		created, err := seedTransactions(ctx, a, user)
+		if err != nil {
+			log.Fatalf("could not seed transactions for %s: %v", user.Username, err)
+		}
+		fmt.Printf("Created %d demo transactions for %s\n", created, user.Username)
+	}
+}
+
+// seedTransactions creates the demo transactions of a user. Order IDs are
+// derived from the user ID so running the seed again skips existing rows.
+func seedTransactions(ctx context.Context, a *app.App, user *models.User) (int, error) {
+	created := 0
+	for i, demo := range demoTransactions {
+		orderID := fmt.Sprintf("SEED-%d-%d", user.ID, i+1)
+		if _, err := a.TransactionRepo.FindByID(ctx, orderID); err == nil {
+			continue
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return created, err
+		}
+
+		createdAt := time.Now().AddDate(0, 0, -demo.DaysAgo)
+		tx := &models.Transaction{
+			ID:          orderID,
+			UserID:      user.ID,
+			Status:      demo.Status,
+			PaymentType: demo.PaymentType,
+			CreatedAt:   createdAt,
+		}
+		for _, line := range demo.Lines {
+			product := demoProducts[line.Product]
+			tx.Amount += product.Price * int64(line.Quantity)
+			tx.Items = append(tx.Items, models.TransactionItem{
+				TransactionID: orderID,
+				ItemID:        product.ID,
+				Name:          product.Name,
+				Price:         product.Price,
+				Quantity:      line.Quantity,
+			})
+		}
+		if demo.Status == "success" || demo.Status == "refund" {
+			settledAt := createdAt.Add(5 * time.Minute)
+			tx.SettledAt = &settledAt
+		}
+
+		if err := a.TransactionRepo.Create(ctx, tx); err != nil {
+			return created, err
+		}
+		if err := a.TransactionRepo.AddStatusHistory(ctx, &models.TransactionStatusHistory{TransactionID: orderID, Status: demo.Status, Source: "seed"}); err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
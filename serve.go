@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/app"
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
+	"github.com/bagussubagja/backend-payment-gateway-go/storage"
+)
+
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal("could not start app", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// note : the port is bound before the database is reached, so liveness
+	// passes while the connection is retried and other requests get a 503
+	var handler atomic.Pointer[http.Handler]
+	setHandler := func(h http.Handler) { handler.Store(&h) }
+	setHandler(startingHandler())
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%s", cfg.ServerPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			(*handler.Load()).ServeHTTP(w, r)
+		}),
+		// note : without a header deadline a client dribbling its headers
+		// holds the connection, and the shutdown drain, open indefinitely
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		fatal("could not start server", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	slog.Info("server is running", "port", cfg.ServerPort)
+
+	a, err := startApp(ctx, cfg)
+	if err != nil {
+		server.Close()
+		fatal("could not start app", err)
+	}
+	stopTracing, err := startTracing(a)
+	if err != nil {
+		server.Close()
+		if sqlDB, err := a.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		fatal("could not start tracing", err)
+	}
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	a.ReportService.StartNightlyJob(jobsCtx)
+	a.DataExportService.StartCleanupJob(jobsCtx)
+
+	server.RegisterOnShutdown(a.EventHub.Close)
+	setHandler(a.Router())
+	slog.Info("server is ready")
+
+	select {
+	case err := <-serveErr:
+		fatal("server stopped unexpectedly", err)
+	case <-ctx.Done():
+	}
+	// note : a second signal stops the process right away
+	stop()
+
+	slog.Info("shutting down", "timeout", a.Config.ShutdownTimeout.String())
+	a.StartDraining()
+	time.Sleep(a.Config.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("could not drain requests", "error", err)
+	}
+	stopJobs()
+	if err := a.Workers.Wait(shutdownCtx); err != nil {
+		slog.Error("background work did not finish", "error", err)
+	}
+	stopTracing()
+
+	if sqlDB, err := a.DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+	slog.Info("server stopped")
+}
+
+// startApp connects to the database, retrying while it is unavailable, and
+// applies pending migrations.
+func startApp(ctx context.Context, cfg *config.Config) (*app.App, error) {
+	db, err := storage.Connect(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to db: %w", err)
+	}
+	slog.Info("database connected", "driver", cfg.DBDriver)
+
+	a, err := app.New(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DBAutoMigrate {
+		migrator, err := newMigrator(a)
+		if err != nil {
+			return nil, err
+		}
+		applied, err := migrator.Up()
+		if err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+		for _, migration := range applied {
+			slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+	return a, nil
+}
+
+// startingHandler answers until the app is ready. Only the liveness probe
+// succeeds.
+func startingHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health/live", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /health/ready", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		writeJSON(w, http.StatusServiceUnavailable, models.ErrorResponse{Error: "server is starting", Code: "SERVER_STARTING", RetryAfter: 5})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/simulator"
+	"github.com/joho/godotenv"
+)
+
+// note : the simulator only reads the values it shares with the server, so it
+// runs without the rest of the configuration
+func runSimulator(args []string) {
+	godotenv.Load()
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	flags := flag.NewFlagSet("midtrans-simulator", flag.ExitOnError)
+	addr := flags.String("addr", ":9090", "address to listen on")
+	serverKey := flags.String("server-key", os.Getenv("MIDTRANS_SERVER_KEY"), "server key clients must use, defaults to MIDTRANS_SERVER_KEY")
+	notificationURL := flags.String("notification-url", "http://localhost:"+port+"/api/v1/payments/notification", "where payment notifications are sent")
+	baseURL := flags.String("base-url", "", "public address of the simulator, defaults to http://localhost<addr>")
+	flags.Parse(args)
+
+	if *serverKey == "" {
+		log.Fatal("midtrans-simulator: --server-key or MIDTRANS_SERVER_KEY is required")
+	}
+	if *baseURL == "" {
+		host := *addr
+		if strings.HasPrefix(host, ":") {
+			host = "localhost" + host
+		}
+		*baseURL = "http://" + host
+	}
+
+	sim := simulator.New(simulator.Config{
+		ServerKey:       *serverKey,
+		NotificationURL: *notificationURL,
+		BaseURL:         *baseURL,
+	})
+
+	log.Printf("Midtrans simulator is running on %s, start the server with MIDTRANS_BASE_URL=%s", *addr, *baseURL)
+	log.Printf("Notifications are sent to %s", *notificationURL)
+	if err := http.ListenAndServe(*addr, sim); err != nil {
+		log.Fatalf("could not start simulator: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const slowQueryThreshold = time.Second
+
+// ParseLogLevel maps DB_LOG_LEVEL to a GORM log level.
+func ParseLogLevel(level string) (logger.LogLevel, error) {
+	switch level {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "warn", "":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	default:
+		return 0, fmt.Errorf("unsupported DB_LOG_LEVEL %q, expected silent, error, warn or info", level)
+	}
+}
+
+// slogLogger writes GORM logs through the request scoped slog logger, so
+// queries carry the request ID once the context reaches GORM. Queries are
+// logged with placeholders only, bound values never reach the logs.
+type slogLogger struct {
+	level logger.LogLevel
+}
+
+func newLogger(level logger.LogLevel) logger.Interface {
+	return &slogLogger{level: level}
+}
+
+func (l *slogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &slogLogger{level: level}
+}
+
+func (l *slogLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		logging.FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		logging.FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		logging.FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level := slog.LevelInfo
+	msg := "query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		level, msg = slog.LevelError, "query failed"
+	case elapsed > slowQueryThreshold && l.level >= logger.Warn:
+		level, msg = slog.LevelWarn, "slow query"
+	case l.level >= logger.Info:
+	default:
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, level, msg, attrs...)
+}
+
+// ParamsFilter keeps GORM from interpolating the bound values into the SQL it
+// hands to Trace.
+func (l *slogLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    full_name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    email TEXT NOT NULL,
+    password TEXT NOT NULL,
+    address TEXT,
+    phone_number TEXT,
+    city TEXT,
+    postal_code TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT uni_users_username UNIQUE (username),
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    status TEXT NOT NULL,
+    midtrans_transaction_id TEXT,
+    payment_url TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT fk_transactions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_items (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id TEXT NOT NULL,
+    item_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    price BIGINT NOT NULL,
+    quantity INTEGER NOT NULL,
+    CONSTRAINT fk_transactions_items FOREIGN KEY (transaction_id) REFERENCES transactions (id)
+);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS data_exports;
+DROP TABLE IF EXISTS transaction_status_histories;
+
+DROP INDEX IF EXISTS idx_transaction_items_transaction_id;
+DROP INDEX IF EXISTS idx_transactions_user_id_created_at;
+DROP INDEX IF EXISTS idx_transactions_payment_type;
+DROP INDEX IF EXISTS idx_transactions_created_at;
+DROP INDEX IF EXISTS idx_transactions_status;
+DROP INDEX IF EXISTS idx_transactions_user_id;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS settled_at;
+ALTER TABLE transactions DROP COLUMN IF EXISTS payment_type;
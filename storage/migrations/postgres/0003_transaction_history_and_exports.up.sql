@@ -0,0 +1,31 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS payment_type TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS settled_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions (user_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_status ON transactions (status);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions (created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_payment_type ON transactions (payment_type);
+-- serves the cursor pagination of the payment history
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id_created_at ON transactions (user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_transaction_items_transaction_id ON transaction_items (transaction_id);
+
+CREATE TABLE IF NOT EXISTS transaction_status_histories (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    source TEXT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_transaction_status_histories_transaction_id ON transaction_status_histories (transaction_id);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    status TEXT NOT NULL,
+    file_path TEXT,
+    error TEXT,
+    created_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports (user_id);
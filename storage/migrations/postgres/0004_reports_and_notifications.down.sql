@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS notification_logs;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS daily_transaction_summaries;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS daily_transaction_summaries (
+    id BIGSERIAL PRIMARY KEY,
+    report_date VARCHAR(10) NOT NULL,
+    payment_type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    transaction_count BIGINT NOT NULL,
+    total_amount BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_summary_key ON daily_transaction_summaries (report_date, payment_type, status);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BIGINT PRIMARY KEY,
+    language VARCHAR(5) NOT NULL,
+    payment_success BOOLEAN NOT NULL,
+    payment_failed BOOLEAN NOT NULL,
+    payment_refunded BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS notification_logs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    transaction_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_notification_logs_user_id ON notification_logs (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_log_key ON notification_logs (transaction_id, event, channel);
@@ -0,0 +1,10 @@
+-- older unfinished exports of a user are given up so the index can be built
+UPDATE data_exports SET status = 'failed', error = 'superseded by a newer export'
+WHERE status IN ('pending', 'processing')
+  AND created_at < (
+    SELECT MAX(newer.created_at) FROM data_exports newer
+    WHERE newer.user_id = data_exports.user_id AND newer.status IN ('pending', 'processing')
+  );
+
+-- at most one export per user is being built at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_unfinished ON data_exports (user_id) WHERE status IN ('pending', 'processing');
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/config"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// NewSQLiteDB opens the SQLite database at DB_SQLITE_PATH, or a private
+// in-memory database for ":memory:". It uses a pure Go driver, so no cgo is
+// needed.
+func NewSQLiteDB(cfg *config.Config) (*gorm.DB, error) {
+	logLevel, err := ParseLogLevel(cfg.DBLogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite", cfg.DBSQLitePath)
+	sqlDB, err := sql.Open(sqlite.DriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// note : SQLite allows one writer at a time, and every connection to
+	// ":memory:" would otherwise get its own empty database
+	sqlDB.SetMaxOpenConns(1)
+
+	db, err := gorm.Open(sqlite.Dialector{Conn: &utcConnPool{db: sqlDB}}, &gorm.Config{
+		Logger:         newLogger(logLevel),
+		NowFunc:        func() time.Time { return time.Now().UTC() },
+		TranslateError: true,
+	})
+	if err == nil {
+		err = usePlugins(db, cfg.DBQueryTimeout)
+	}
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// utcConnPool converts time arguments to UTC before they reach SQLite. Times
+// are stored as text there, so values written with different offsets would
+// otherwise not compare or sort correctly.
+type utcConnPool struct {
+	db *sql.DB
+}
+
+func (p *utcConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, query)
+}
+
+func (p *utcConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.db.ExecContext(ctx, query, toUTC(args)...)
+}
+
+func (p *utcConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.db.QueryContext(ctx, query, toUTC(args)...)
+}
+
+func (p *utcConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRowContext(ctx, query, toUTC(args)...)
+}
+
+func (p *utcConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &utcTx{tx: tx}, nil
+}
+
+func (p *utcConnPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+type utcTx struct {
+	tx *sql.Tx
+}
+
+func (t *utcTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.tx.PrepareContext(ctx, query)
+}
+
+func (t *utcTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, toUTC(args)...)
+}
+
+func (t *utcTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, toUTC(args)...)
+}
+
+func (t *utcTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, toUTC(args)...)
+}
+
+func (t *utcTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *utcTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func toUTC(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				args[i] = v.UTC()
+			}
+		}
+	}
+	return args
+}
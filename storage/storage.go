@@ -1,51 +1,133 @@
 package storage
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
 	"github.com/bagussubagja/backend-payment-gateway-go/config"
-	"github.com/bagussubagja/backend-payment-gateway-go/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Backoff between connection attempts in Connect, doubled after every
+// failure.
+const (
+	connectRetryMinDelay = 500 * time.Millisecond
+	connectRetryMaxDelay = 10 * time.Second
+)
+
+// NewDB opens the database selected by DB_DRIVER.
+func NewDB(cfg *config.Config) (*gorm.DB, error) {
+	switch cfg.DBDriver {
+	case DriverPostgres, "":
+		return NewPostgresDB(cfg)
+	case DriverSQLite:
+		return NewSQLiteDB(cfg)
+	default:
+		return nil, unsupportedDriverError(cfg.DBDriver)
+	}
+}
+
+// Connect opens the database like NewDB, but retries failed connections with
+// exponential backoff for up to DB_CONNECT_TIMEOUT or until ctx is done, so
+// the server rides out a database that is restarting or not up yet.
+// Configuration errors are returned right away.
+func Connect(ctx context.Context, cfg *config.Config) (*gorm.DB, error) {
+	if err := checkConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(cfg.DBConnectTimeout)
+	delay := connectRetryMinDelay
+	for attempt := 1; ; attempt++ {
+		db, err := NewDB(cfg)
+		if err == nil {
+			return db, nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		slog.Warn("could not connect to db, retrying", "attempt", attempt, "retry_in", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, connectRetryMaxDelay)
+	}
+}
+
+func checkConfig(cfg *config.Config) error {
+	if _, err := ParseLogLevel(cfg.DBLogLevel); err != nil {
+		return err
+	}
+	switch cfg.DBDriver {
+	case DriverPostgres, "":
+		return checkPostgresConfig(cfg)
+	case DriverSQLite:
+		return nil
+	default:
+		return unsupportedDriverError(cfg.DBDriver)
+	}
+}
+
+func checkPostgresConfig(cfg *config.Config) error {
+	if cfg.DBHost == "" || cfg.DBPort == "" || cfg.DBUser == "" || cfg.DBName == "" {
+		return fmt.Errorf("DB_HOST, DB_PORT, DB_USER and DB_NAME are required for the postgres driver")
+	}
+	return nil
+}
+
+func unsupportedDriverError(driver string) error {
+	return fmt.Errorf("unsupported DB_DRIVER %q, expected %s or %s", driver, DriverPostgres, DriverSQLite)
+}
+
 func NewPostgresDB(cfg *config.Config) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=require TimeZone=Asia/Jakarta prefer_simple_protocol=true",
+	if err := checkPostgresConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	logLevel, err := ParseLogLevel(cfg.DBLogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Jakarta prefer_simple_protocol=true",
 		cfg.DBHost,
 		cfg.DBUser,
 		cfg.DBPassword,
 		cfg.DBName,
 		cfg.DBPort,
-	)
-
-	newLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Info,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  true,
-		},
+		cfg.DBSSLMode,
 	)
 
 	db, err := gorm.Open(postgres.New(postgres.Config{
 		DSN:                  dsn,
 		PreferSimpleProtocol: true,
 	}), &gorm.Config{
-		Logger: newLogger,
+		Logger:         newLogger(logLevel),
+		TranslateError: true,
 	})
 
-	if err != nil {
-		return nil, err
+	if err == nil {
+		err = usePlugins(db, cfg.DBQueryTimeout)
 	}
-
-	// note : auto migrate DB
-	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.TransactionItem{})
 	if err != nil {
+		// note : gorm returns the pool even when the first ping fails, it is
+		// closed so retries in Connect do not leak it
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
 		return nil, err
 	}
 
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	timeoutCancelKey    = "timeout:cancel"
+	timeoutParentCtxKey = "timeout:parent_ctx"
+)
+
+// timeoutPlugin bounds every statement, including its preloads, by
+// DB_QUERY_TIMEOUT on top of whatever deadline the caller's context has.
+// Row queries are left alone since their rows are read after the callbacks
+// return, which is how exports stream.
+type timeoutPlugin struct {
+	timeout time.Duration
+}
+
+func (timeoutPlugin) Name() string {
+	return "timeout"
+}
+
+func (p timeoutPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register("timeout:before_create", p.start),
+		cb.Create().After("*").Register("timeout:after_create", p.end),
+		cb.Query().Before("*").Register("timeout:before_query", p.start),
+		cb.Query().After("*").Register("timeout:after_query", p.end),
+		cb.Update().Before("*").Register("timeout:before_update", p.start),
+		cb.Update().After("*").Register("timeout:after_update", p.end),
+		cb.Delete().Before("*").Register("timeout:before_delete", p.start),
+		cb.Delete().After("*").Register("timeout:after_delete", p.end),
+		cb.Raw().Before("*").Register("timeout:before_raw", p.start),
+		cb.Raw().After("*").Register("timeout:after_raw", p.end),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p timeoutPlugin) start(db *gorm.DB) {
+	parent := db.Statement.Context
+	ctx, cancel := context.WithTimeout(parent, p.timeout)
+	db.Statement.Context = ctx
+	db.InstanceSet(timeoutCancelKey, cancel)
+	db.InstanceSet(timeoutParentCtxKey, parent)
+}
+
+func (p timeoutPlugin) end(db *gorm.DB) {
+	if cancel, ok := db.InstanceGet(timeoutCancelKey); ok {
+		cancel.(context.CancelFunc)()
+	}
+	if parent, ok := db.InstanceGet(timeoutParentCtxKey); ok {
+		db.Statement.Context = parent.(context.Context)
+	}
+}
+
+// usePlugins registers the plugins shared by every driver.
+func usePlugins(db *gorm.DB, queryTimeout time.Duration) error {
+	if err := db.Use(tracingPlugin{}); err != nil {
+		return err
+	}
+	if queryTimeout > 0 {
+		return db.Use(timeoutPlugin{timeout: queryTimeout})
+	}
+	return nil
+}
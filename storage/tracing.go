@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bagussubagja/backend-payment-gateway-go/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	tracingSpanKey      = "tracing:span"
+	tracingParentCtxKey = "tracing:parent_ctx"
+)
+
+// tracingPlugin opens a span around every GORM statement. The span becomes a
+// child of the request when the query runs with WithContext. Like the logger
+// it records the SQL with placeholders only.
+type tracingPlugin struct{}
+
+func (tracingPlugin) Name() string {
+	return "tracing"
+}
+
+func (tracingPlugin) Initialize(db *gorm.DB) error {
+	system := db.Dialector.Name()
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", startSpan("INSERT", system)),
+		cb.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", startSpan("SELECT", system)),
+		cb.Query().After("gorm:query").Register("tracing:after_query", endSpan),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", startSpan("UPDATE", system)),
+		cb.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("DELETE", system)),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", startSpan("SELECT", system)),
+		cb.Row().After("gorm:row").Register("tracing:after_row", endSpan),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("RAW", system)),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startSpan(operation, system string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		name := operation
+		if table := db.Statement.Table; table != "" {
+			name += " " + table
+		}
+		parent := db.Statement.Context
+		ctx, span := tracing.Start(parent, name,
+			semconv.DBSystemKey.String(system),
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(db.Statement.Table),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(tracingSpanKey, span)
+		db.InstanceSet(tracingParentCtxKey, parent)
+	}
+}
+
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+
+	// note : preloads run after the statement, restoring the parent keeps
+	// them siblings of this span instead of children of an ended one
+	if parent, ok := db.InstanceGet(tracingParentCtxKey); ok {
+		db.Statement.Context = parent.(context.Context)
+	}
+
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	tracing.End(span, err)
+}